@@ -0,0 +1,205 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// Default limit on the number of records a buffer holds.
+const DefaultBufferSize = 1000
+
+// WithBuffering makes the logger open its facility at priority level, if
+// that is more verbose than the logger level, so that buffers created
+// with NewBuffer can hold records of priorities up to level. The logger
+// itself logs records up to its own level as usual.
+func WithBuffering(level Priority) Option {
+	return func(l *sLogger) {
+		l.buffering = level
+	}
+}
+
+type bufferEntry struct {
+	log    *sLog
+	logger *log.Logger
+	rec    *Record
+	raw    bool
+}
+
+// Buffer holds records of a scope, such as a request, in memory, and
+// writes them out only if something goes wrong in the scope, i.e. on
+// "fingers crossed" basis. Records logged with the buffer logger that are
+// more verbose than the threshold are held, and the rest are logged
+// right away. Once a record of the trigger priority or more severe is
+// logged, the held records are written ahead of it between "Buffered
+// records begin" and "Buffered records end" markers, and the buffer
+// passes all records through from then on. If the buffer is closed
+// without being triggered, held records are discarded.
+//
+// The buffer holds up to its size of the latest records. Older ones are
+// dropped and counted in "dropped" field of the begin marker. Held
+// records keep values as they were when logged, and their call sites.
+// Output of loggers returned by Log.Logger is not buffered.
+type Buffer struct {
+	logger    Logger
+	threshold Priority
+	trigger   Priority
+	size      int
+	mux       sync.Mutex
+	entries   []bufferEntry
+	start     int
+	dropped   int
+	triggered bool
+	closed    bool
+}
+
+// NewBuffer creates a buffer for logger l that holds records of
+// priorities more verbose than threshold, up to size of them, until
+// a record of priority trigger or more severe is logged. A custom trigger
+// priority matches only itself. Records more verbose than the logger
+// level can be held only if the logger was created with WithBuffering.
+func NewBuffer(l Logger, threshold, trigger Priority, size int) *Buffer {
+	if size < 1 {
+		size = DefaultBufferSize
+	}
+	res := &Buffer{threshold: threshold, trigger: trigger, size: size}
+	sl, ok := l.(*sLogger)
+	if !ok {
+		res.logger = l
+		return res
+	}
+	if sl.buffered != nil {
+		sl = sl.buffered
+	}
+	bl := *sl
+	bl.logs = make(map[Priority]Log, len(sl.logs))
+	for p, lg := range sl.logs {
+		if s, ok := lg.(*sLog); ok && s != drain && s.logger != dscrd && !s.recordOnly {
+			ns := *s
+			ns.buffer = res
+			ns.hold = p.Bound() > threshold
+			lg = &ns
+		}
+		bl.logs[p] = lg
+	}
+	res.logger = &bl
+	return res
+}
+
+// Logger returns the logger that logs through the buffer.
+func (this *Buffer) Logger() Logger {
+	return this.logger
+}
+
+// Flush writes the held records out as a trigger record would, and makes
+// the buffer pass all records through from then on.
+func (this *Buffer) Flush() {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if !this.closed && !this.triggered {
+		this.triggered = true
+		this.flush()
+	}
+}
+
+// Close ends the scope of the buffer, discarding the held records unless
+// it was triggered. Records logged afterwards that would have been held
+// are discarded as well.
+func (this *Buffer) Close() {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.closed = true
+	this.entries, this.start, this.dropped = nil, 0, 0
+}
+
+// Triggered reports whether the buffer was triggered or flushed.
+func (this *Buffer) Triggered() bool {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return this.triggered
+}
+
+func (this *Buffer) triggers(pri Priority) bool {
+	if customPriority(this.trigger) != nil {
+		return pri == this.trigger
+	}
+	return pri.Bound() <= this.trigger
+}
+
+// admit reports whether the record is to be written right away, holding
+// it otherwise. The argument raw tells unstructured output from records.
+func (this *Buffer) admit(l *sLog, logger *log.Logger, rec *Record, raw bool) bool {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	switch {
+	case this.closed:
+		return !l.hold
+	case this.triggered:
+		return true
+	case this.triggers(rec.Priority):
+		this.triggered = true
+		this.flush()
+		return true
+	case !l.hold:
+		return true
+	}
+	e := bufferEntry{log: l, logger: logger, rec: rec, raw: raw}
+	if len(this.entries) < this.size {
+		this.entries = append(this.entries, e)
+	} else {
+		this.entries[this.start] = e
+		this.start = (this.start + 1) % this.size
+		this.dropped++
+	}
+	return false
+}
+
+// flush writes the held records out between markers.
+func (this *Buffer) flush() {
+	if len(this.entries) == 0 {
+		return
+	}
+	first := this.entries[this.start]
+	v := []interface{}{"records", len(this.entries)}
+	if this.dropped > 0 {
+		v = append(v, "dropped", this.dropped)
+	}
+	first.log.emit(first.logger, 1, &Record{Time: first.log.clock.Now(), Priority: first.log.pri, Message: "Buffered records begin", Values: v})
+	for i := range this.entries {
+		e := this.entries[(this.start+i)%len(this.entries)]
+		if e.raw {
+			e.log.output(e.logger, 1, e.rec.Time, e.rec.Message)
+		} else {
+			e.log.emit(e.logger, 1, e.rec)
+		}
+	}
+	first.log.emit(first.logger, 1, &Record{Time: first.log.clock.Now(), Priority: first.log.pri, Message: "Buffered records end"})
+	this.entries, this.start, this.dropped = nil, 0, 0
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries logger l.
+func NewContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or the shared logger if
+// there is none.
+func FromContext(ctx context.Context) Logger {
+	if l, ok := ctx.Value(contextKey{}).(Logger); ok {
+		return l
+	}
+	return SharedLogger()
+}
+
+// NewBufferContext creates a buffer for the logger carried by ctx as
+// NewBuffer does, and returns a copy of ctx that carries the buffer
+// logger along with the buffer. The caller closes the buffer at the end
+// of the scope.
+func NewBufferContext(ctx context.Context, threshold, trigger Priority, size int) (context.Context, *Buffer) {
+	b := NewBuffer(FromContext(ctx), threshold, trigger, size)
+	return NewContext(ctx, b.Logger()), b
+}
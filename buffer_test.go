@@ -0,0 +1,95 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestBuffer(tst *testing.T) {
+	f := &tFacility{}
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil, WithBuffering(PriorityTrace))
+	l.Debug().Prints("not logged")
+	if res := f.lines(); len(res) != 0 {
+		tst.Errorf("fail: expected no output, but had %q", res)
+	}
+
+	ctx, b := NewBufferContext(NewContext(context.Background(), l), PriorityInfo, PriorityError, 2)
+	bl := FromContext(ctx)
+	if bl != b.Logger() {
+		tst.Errorf("fail: expected buffer logger in context")
+	}
+	bl.Debug().Prints("first")
+	bl.Info().Prints("started")
+	bl.Debug().Printf("raw %d", 1)
+	bl.WithGroup("req").Debug().Prints("second", "id", 7)
+	if res := f.lines(); strings.Join(res, "|") != "INFO started" {
+		tst.Errorf("fail: expected only info record, but had %q", res)
+	}
+	bl.Error().Prints("failed")
+	bl.Debug().Prints("after")
+	b.Close()
+	exp := []string{
+		"TRACE Buffered records begin records=2 dropped=1",
+		"TRACE raw 1",
+		"TRACE second req.id=7",
+		"TRACE Buffered records end",
+		"ERROR failed",
+		"TRACE after",
+	}
+	if res := f.lines(); strings.Join(res, "|") != strings.Join(exp, "|") {
+		tst.Errorf("fail: expected %q, but had %q", exp, res)
+	}
+	if !b.Triggered() {
+		tst.Errorf("fail: expected buffer to be triggered")
+	}
+
+	b = NewBuffer(l, PriorityInfo, PriorityError, 0)
+	b.Logger().Debug().Prints("discarded")
+	b.Logger().Warning().Prints("warned")
+	b.Close()
+	b.Logger().Debug().Prints("discarded")
+	b.Logger().Error().Prints("failed")
+	if res := f.lines(); strings.Join(res, "|") != "WARNING warned|ERROR failed" {
+		tst.Errorf("fail: unexpected output %q", res)
+	}
+
+	b = NewBuffer(l.WithGroup("req"), PriorityInfo, PriorityError, 0)
+	b.Logger().Debug().Prints("grouped", "id", 8)
+	b.Flush()
+	if res := f.lines(); strings.Join(res, "|") != "TRACE Buffered records begin records=1|TRACE grouped req.id=8|TRACE Buffered records end" {
+		tst.Errorf("fail: unexpected output %q", res)
+	}
+
+	b = NewBuffer(l, PriorityInfo, PriorityError, 0)
+	b.Logger().Debug().Prints("kept")
+	b.Flush()
+	if res := f.lines(); strings.Join(res, "|") != "TRACE Buffered records begin records=1|TRACE kept|TRACE Buffered records end" {
+		tst.Errorf("fail: unexpected output %q", res)
+	}
+}
+
+func TestBufferHeldRecords(tst *testing.T) {
+	f := &tFacility{flags: log.Lshortfile}
+	l, _ := New(f, PriorityDebug, SimpleFormatter, nil)
+	b := NewBuffer(l, PriorityInfo, PriorityError, 0)
+	m := map[string]int{"a": 1}
+	held := lineOf(func() { b.Logger().Debug().Prints("held", "m", m) })
+	raw := lineOf(func() { b.Logger().Debug().Printf("raw") })
+	m["a"] = 2
+	trigger := lineOf(func() { b.Logger().Error().Prints("failed") })
+	exp := []string{
+		fmt.Sprintf("TRACE buffer_test.go:%d: Buffered records begin records=2", held),
+		fmt.Sprintf("TRACE buffer_test.go:%d: held m.a=1", held),
+		fmt.Sprintf("TRACE buffer_test.go:%d: raw", raw),
+		fmt.Sprintf("TRACE buffer_test.go:%d: Buffered records end", held),
+		fmt.Sprintf("ERROR buffer_test.go:%d: failed", trigger),
+	}
+	if res := f.lines(); strings.Join(res, "|") != strings.Join(exp, "|") {
+		tst.Errorf("fail: expected %q, but had %q", exp, res)
+	}
+}
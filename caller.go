@@ -0,0 +1,164 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Detail of caller information recorded as caller field.
+type CallerMode int
+
+const (
+	// No caller information.
+	CallerOff CallerMode = iota
+	// File name and line, e.g. "conn.go:42".
+	CallerShort
+	// Full file path and line.
+	CallerFull
+	// File path relative to the main module and line, e.g. "db/conn.go:42".
+	// Files outside the main module are reported with their package
+	// import path.
+	CallerModule
+	// Package name and function, e.g. "db.(*Conn).Open".
+	CallerFunc
+)
+
+var callerModeNames = map[CallerMode]string{
+	CallerOff:    "off",
+	CallerShort:  "short",
+	CallerFull:   "full",
+	CallerModule: "module",
+	CallerFunc:   "func",
+}
+
+func ParseCallerMode(s string) (CallerMode, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for m, n := range callerModeNames {
+		if name == n {
+			return m, nil
+		}
+	}
+	return CallerOff, fmt.Errorf("unsupported caller mode: %q", s)
+}
+
+func (this CallerMode) String() string {
+	return callerModeNames[this]
+}
+
+// WithCaller sets caller detail for records of the specified priorities,
+// or of all priorities if none are specified. By default records have no
+// caller field, and trace records carry short caller in the header, as
+// with log.Lshortfile. Setting a mode other than CallerOff for a priority
+// replaces the header caller with the caller field.
+func WithCaller(mode CallerMode, pris ...Priority) Option {
+	return func(l *sLogger) {
+		if len(pris) == 0 {
+			for pri := PriorityEmergency; pri <= PriorityTrace; pri++ {
+				l.callers[pri] = mode
+			}
+		}
+		for _, pri := range pris {
+			l.callers[pri.Bound()] = mode
+		}
+	}
+}
+
+func defaultCallers() map[Priority]CallerMode {
+	return map[Priority]CallerMode{}
+}
+
+var (
+	helpers     sync.Map
+	helperCount int32
+)
+
+// Helper marks the calling function as a logging helper. Helper functions
+// are skipped when reporting caller information and applying trace
+// filter, much like with testing.T.Helper.
+func Helper() {
+	pcs := make([]uintptr, 1)
+	if runtime.Callers(2, pcs) == 0 {
+		return
+	}
+	f, _ := runtime.CallersFrames(pcs).Next()
+	if _, loaded := helpers.LoadOrStore(f.Function, struct{}{}); !loaded {
+		atomic.AddInt32(&helperCount, 1)
+	}
+}
+
+// callerFrame returns the frame skip levels up the stack from the caller
+// of callerFrame, further skipping the helper functions.
+func callerFrame(skip int) (runtime.Frame, bool) {
+	if atomic.LoadInt32(&helperCount) == 0 {
+		pcs := make([]uintptr, 1)
+		if runtime.Callers(skip+2, pcs) == 0 {
+			return runtime.Frame{}, false
+		}
+		f, _ := runtime.CallersFrames(pcs).Next()
+		return f, true
+	}
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for n > 0 {
+		f, more := frames.Next()
+		if _, ok := helpers.Load(f.Function); !ok || !more {
+			return f, true
+		}
+	}
+	return runtime.Frame{}, false
+}
+
+func formatCaller(f runtime.Frame, mode CallerMode) string {
+	switch mode {
+	case CallerShort:
+		return fmt.Sprintf("%s:%d", filepath.Base(f.File), f.Line)
+	case CallerFull:
+		return fmt.Sprintf("%s:%d", f.File, f.Line)
+	case CallerModule:
+		return fmt.Sprintf("%s:%d", modulePath(f), f.Line)
+	case CallerFunc:
+		if i := strings.LastIndex(f.Function, "/"); i >= 0 {
+			return f.Function[i+1:]
+		}
+		return f.Function
+	}
+	return ""
+}
+
+var mainModule = func() string {
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		return bi.Main.Path
+	}
+	return ""
+}()
+
+// modulePath returns file path of the frame relative to the main module.
+func modulePath(f runtime.Frame) string {
+	pkg := f.Function
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		if j := strings.Index(pkg[i:], "."); j >= 0 {
+			pkg = pkg[:i+j]
+		}
+	} else if j := strings.Index(pkg, "."); j >= 0 {
+		pkg = pkg[:j]
+	}
+	file := filepath.Base(f.File)
+	switch {
+	case pkg == "main":
+		// Import path of main package is not known, use its directory
+		return filepath.Base(filepath.Dir(f.File)) + "/" + file
+	case len(mainModule) > 0 && pkg == mainModule:
+		return file
+	case len(mainModule) > 0 && strings.HasPrefix(pkg, mainModule+"/"):
+		return pkg[len(mainModule)+1:] + "/" + file
+	}
+	return pkg + "/" + file
+}
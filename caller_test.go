@@ -0,0 +1,67 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func logVia(l Log, message string) {
+	Helper()
+	l.Prints(message)
+}
+
+// lineOf calls f and returns the line it is called from, where f is
+// meant to be written on the same line.
+func lineOf(f func()) int {
+	_, _, line, _ := runtime.Caller(1)
+	f()
+	return line
+}
+
+func TestCaller(tst *testing.T) {
+	f := &tFacility{}
+	for _, t := range []struct {
+		mode CallerMode
+		res  string
+	}{
+		{CallerShort, "caller_test.go:"},
+		{CallerFull, "/caller_test.go:"},
+		{CallerFunc, "slog.TestCaller"},
+	} {
+		l, _ := New(f, PriorityInfo, SimpleFormatter, nil, WithCaller(t.mode, PriorityInfo))
+		direct := lineOf(func() { l.Info().Prints("direct") })
+		helper := lineOf(func() { logVia(l.Info(), "helper") })
+		l.Notice().Prints("off")
+		res := f.lines()
+		if len(res) != 3 || !strings.Contains(res[0], t.res) || res[2] != "NOTICE off" {
+			tst.Errorf("fail: %v: unexpected output %q", t.mode, res)
+			continue
+		}
+		if i := strings.Index(res[0], "caller="); t.mode != CallerFunc && !strings.HasSuffix(res[0], fmt.Sprint(":", direct)) {
+			tst.Errorf("fail: %v: unexpected caller %q", t.mode, res[0][i:])
+		}
+		if i := strings.Index(res[1], "caller="); t.mode != CallerFunc && !strings.HasSuffix(res[1], fmt.Sprint(":", helper)) {
+			tst.Errorf("fail: %v: helper not skipped in %q", t.mode, res[1][i:])
+		}
+	}
+}
+
+func TestCallerHeader(tst *testing.T) {
+	f := &tFacility{flags: log.Lshortfile}
+	l, _ := New(f, PriorityDebug, SimpleFormatter, nil)
+	header := lineOf(func() { l.Debug().Prints("header") })
+	l, _ = New(f, PriorityDebug, SimpleFormatter, nil, WithCaller(CallerShort))
+	field := lineOf(func() { l.Debug().Prints("field") })
+	exp := []string{
+		fmt.Sprintf("TRACE caller_test.go:%d: header", header),
+		fmt.Sprintf("TRACE field caller=caller_test.go:%d", field),
+	}
+	if res := f.lines(); strings.Join(res, "|") != strings.Join(exp, "|") {
+		tst.Errorf("fail: expected %q, but had %q", exp, res)
+	}
+}
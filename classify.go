@@ -0,0 +1,201 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+)
+
+// ErrorRule assigns priority to errors reported with On, With and Success
+// selectors.
+type ErrorRule struct {
+	// Name of the rule, recorded in output as error_rule.
+	Name string
+	// Match reports whether the rule applies to an error.
+	Match func(error) bool
+	// Priority to log matching errors with.
+	Priority Priority
+	// Suppress matching errors instead of logging them.
+	Suppress bool
+}
+
+// ErrorIs returns a matcher for errors that wrap target.
+func ErrorIs(target error) func(error) bool {
+	return func(err error) bool {
+		return errors.Is(err, target)
+	}
+}
+
+// ErrorAs returns a matcher for errors that wrap an error assignable to
+// the variable target points to, as with errors.As.
+func ErrorAs(target interface{}) func(error) bool {
+	typ := reflect.TypeOf(target)
+	if typ == nil || typ.Kind() != reflect.Ptr {
+		panic("slog: ErrorAs target must be a non-nil pointer")
+	}
+	return func(err error) bool {
+		return errors.As(err, reflect.New(typ.Elem()).Interface())
+	}
+}
+
+// ErrorClassifier maps errors to priorities by the first matching rule.
+// Errors that match no rule are logged with PriorityError.
+// Errors already logged with Selector.Return are skipped unless
+// SetLoggedPriority says otherwise.
+type ErrorClassifier struct {
+	mux     sync.RWMutex
+	rules   []ErrorRule
+	logged  ErrorRule
+	success Priority
+}
+
+func NewErrorClassifier(rules ...ErrorRule) *ErrorClassifier {
+	return &ErrorClassifier{
+		rules:   rules,
+		logged:  ErrorRule{Name: "logged", Match: IsLogged, Suppress: true},
+		success: PriorityNotice,
+	}
+}
+
+// Add appends rules to the classifier.
+func (this *ErrorClassifier) Add(rules ...ErrorRule) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.rules = append(this.rules, rules...)
+}
+
+// SetSuccess sets the priority of success records. Default is PriorityNotice.
+func (this *ErrorClassifier) SetSuccess(pri Priority) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.success = pri
+}
+
+// SetLoggedPriority makes errors already logged with Selector.Return to be
+// logged again with pri instead of being skipped.
+func (this *ErrorClassifier) SetLoggedPriority(pri Priority) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.logged = ErrorRule{Name: "logged", Match: IsLogged, Priority: pri}
+}
+
+// Classify returns the first rule that matches err.
+func (this *ErrorClassifier) Classify(err error) (ErrorRule, bool) {
+	this.mux.RLock()
+	defer this.mux.RUnlock()
+	if this.logged.Match(err) {
+		return this.logged, true
+	}
+	for _, r := range this.rules {
+		if r.Match != nil && r.Match(err) {
+			return r, true
+		}
+	}
+	return ErrorRule{}, false
+}
+
+func (this *ErrorClassifier) successPriority() Priority {
+	this.mux.RLock()
+	defer this.mux.RUnlock()
+	return this.success
+}
+
+// outcome determines record priority for the errors. The most severe
+// priority wins. The rule that determined it, if any, is returned too.
+// All errors being suppressed results in ok being false.
+func (this *ErrorClassifier) outcome(errs []error) (pri Priority, rule string, ok bool) {
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		r, matched := this.Classify(err)
+		if !matched {
+			r = ErrorRule{Priority: PriorityError}
+		}
+		if r.Suppress {
+			continue
+		}
+		if !ok || r.Priority.Bound() < pri.Bound() {
+			pri, rule, ok = r.Priority, r.Name, true
+		}
+	}
+	return pri, rule, ok
+}
+
+type loggedError struct {
+	err error
+}
+
+func (this *loggedError) Error() string {
+	return this.err.Error()
+}
+
+func (this *loggedError) Unwrap() error {
+	return this.err
+}
+
+// IsLogged reports whether err has been logged with Selector.Return.
+func IsLogged(err error) bool {
+	var le *loggedError
+	return errors.As(err, &le)
+}
+
+// combineErrors combines errs into a single error.
+func combineErrors(errs []error) error {
+	es := make([]error, 0, len(errs))
+	for _, e := range errs {
+		if e != nil && e != errSuccess && e != errEllipsis {
+			es = append(es, e)
+		}
+	}
+	switch len(es) {
+	case 0:
+		return nil
+	case 1:
+		return es[0]
+	default:
+		return errors.Join(es...)
+	}
+}
+
+// markLogged combines errs into a single error marked as logged.
+func markLogged(errs []error) error {
+	err := combineErrors(errs)
+	if err == nil || IsLogged(err) {
+		return err
+	}
+	return &loggedError{err: err}
+}
+
+var (
+	errorClassifierMu sync.RWMutex
+	errorClassifier   = NewErrorClassifier()
+)
+
+// SetErrorClassifier replaces the classifier used by loggers that were not
+// given one of their own with WithErrorClassifier option.
+func SetErrorClassifier(c *ErrorClassifier) {
+	if c == nil {
+		c = NewErrorClassifier()
+	}
+	errorClassifierMu.Lock()
+	defer errorClassifierMu.Unlock()
+	errorClassifier = c
+}
+
+// DefaultErrorClassifier returns the classifier set with SetErrorClassifier.
+func DefaultErrorClassifier() *ErrorClassifier {
+	errorClassifierMu.RLock()
+	defer errorClassifierMu.RUnlock()
+	return errorClassifier
+}
+
+// WithErrorClassifier makes the logger classify errors with c instead of
+// the default classifier.
+func WithErrorClassifier(c *ErrorClassifier) Option {
+	return func(l *sLogger) {
+		l.classifier = c
+	}
+}
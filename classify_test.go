@@ -0,0 +1,90 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestErrorClassifier(tst *testing.T) {
+	c := NewErrorClassifier(
+		ErrorRule{Name: "eof", Match: ErrorIs(io.EOF), Suppress: true},
+		ErrorRule{Name: "canceled", Match: ErrorIs(context.Canceled), Priority: PriorityInfo},
+		ErrorRule{Name: "path", Match: ErrorAs(new(*os.PathError)), Priority: PriorityWarn},
+	)
+	c.SetSuccess(PriorityInfo)
+	f := &tFacility{}
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil, WithErrorClassifier(c))
+	l.On(io.EOF).Prints("read")
+	l.On(fmt.Errorf("request: %w", context.Canceled)).Prints("call", "id", 1)
+	l.On(&os.PathError{Op: "open", Path: "x", Err: io.ErrUnexpectedEOF}).Prints("open")
+	l.On(io.EOF, io.ErrClosedPipe).Prints("write")
+	l.Success().Prints("done")
+	l.On(nil).Prints("nothing")
+	exp := []string{
+		"INFO call id=1 error_rule=canceled - error=request: context canceled [*fmt.wrapError > *errors.errorString]",
+		"WARNING open error_rule=path - error=open x: unexpected EOF [*fs.PathError > *errors.errorString]",
+		"ERROR write - \n\terror=EOF\n\terror=io: read/write on closed pipe",
+		"INFO done - success",
+	}
+	if res := strings.Join(f.lines(), "\n"); res != strings.Join(exp, "\n") {
+		tst.Errorf("fail: expected %q, but had %q", strings.Join(exp, "\n"), res)
+	}
+}
+
+func TestReturnLogsOnce(tst *testing.T) {
+	f := &tFacility{}
+	c := NewErrorClassifier()
+	l, _ := New(f, PriorityDebug, SimpleFormatter, nil, WithErrorClassifier(c))
+	err := l.On(io.EOF).Return("read")
+	if !IsLogged(err) || !errors.Is(err, io.EOF) || err.Error() != io.EOF.Error() {
+		tst.Errorf("fail: unexpected returned error %#v", err)
+	}
+	wrapped := fmt.Errorf("load: %w", err)
+	if l.On(wrapped).Return("load") != wrapped {
+		tst.Errorf("fail: logged error wrapped again")
+	}
+	c.SetLoggedPriority(PriorityDebug)
+	l.With(wrapped).Prints("main")
+	if l.On(nil).Return("nothing") != nil || l.Success().Return("done") != nil {
+		tst.Errorf("fail: expected nil error on success")
+	}
+	exp := []string{
+		"ERROR read - error=EOF",
+		"TRACE main error_rule=logged - error=load: EOF [*fmt.wrapError > *errors.errorString]",
+		"NOTICE done - success",
+	}
+	if res := strings.Join(f.lines(), "\n"); res != strings.Join(exp, "\n") {
+		tst.Errorf("fail: expected %q, but had %q", strings.Join(exp, "\n"), res)
+	}
+}
+
+func TestReturnUnwritten(tst *testing.T) {
+	f := &tFacility{}
+	c := NewErrorClassifier(
+		ErrorRule{Name: "eof", Match: ErrorIs(io.EOF), Suppress: true},
+		ErrorRule{Name: "minor", Match: ErrorIs(io.ErrUnexpectedEOF), Priority: PriorityInfo},
+	)
+	l, _ := New(f, PriorityError, SimpleFormatter, nil, WithErrorClassifier(c))
+	for _, t := range []struct {
+		err    error
+		logged bool
+	}{
+		{io.EOF, false},
+		{io.ErrUnexpectedEOF, false},
+		{io.ErrClosedPipe, true},
+	} {
+		if err := l.On(t.err).Return("fail"); !errors.Is(err, t.err) || IsLogged(err) != t.logged {
+			tst.Errorf("fail: expected %v logged %v, but had %#v", t.err, t.logged, err)
+		}
+	}
+	if lines := f.lines(); len(lines) != 1 {
+		tst.Errorf("fail: expected 1 record, but had %q", lines)
+	}
+}
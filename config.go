@@ -0,0 +1,432 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config describes logging destinations, level, format and trace filter.
+// Zero values select the defaults.
+type Config struct {
+	// Logging level: "emergency", "alert", "critical", "error", "warn",
+	// "notice", "info" or the name of a custom priority.
+	Level string `json:"level,omitempty" yaml:"level,omitempty"`
+	// Trace verbosity. Takes precedence over Level when positive.
+	Trace uint `json:"trace,omitempty" yaml:"trace,omitempty"`
+	// Source file suffixes to limit trace logging to.
+	Filter []string `json:"filter,omitempty" yaml:"filter,omitempty"`
+	// Output format: "simple", "json", "json-pretty" or a layout pattern,
+	// see Layout.
+	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	// Destinations: file names or "stdout", "stderr" and "syslog".
+	Log []string `json:"log,omitempty" yaml:"log,omitempty"`
+	// Priority at and above which records include stack trace.
+	Stack string `json:"stack,omitempty" yaml:"stack,omitempty"`
+	// Caller detail for all priorities: "off", "short", "full", "module"
+	// or "func". By default trace records include short caller in the
+	// header.
+	Caller string `json:"caller,omitempty" yaml:"caller,omitempty"`
+	// Timestamp layout: "default", "rfc3339", "rfc3339-micro",
+	// "rfc3339-nano", "unix", "unix-ms", "unix-us", "unix-ns", "none"
+	// or a time.Format layout.
+	Time string `json:"time,omitempty" yaml:"time,omitempty"`
+	// Timestamp zone: "Local", "UTC" or a time zone database name.
+	TimeZone string `json:"time_zone,omitempty" yaml:"time_zone,omitempty"`
+	// Sampling rates by priority, e.g. "trace=0.01,info=0.5".
+	Sample string `json:"sample,omitempty" yaml:"sample,omitempty"`
+	// Keys present in the file the configuration was loaded from.
+	set map[string]bool
+}
+
+// Default interval between configuration file checks.
+const DefaultWatchInterval = 5 * time.Second
+
+// FlagSet is implemented by flag.FlagSet of Go 1.16 and later, which added
+// Func method, and by compatible flag packages, such as pflag.
+type FlagSet interface {
+	StringVar(p *string, name string, value string, usage string)
+	UintVar(p *uint, name string, value uint, usage string)
+	Func(name string, usage string, fn func(string) error)
+}
+
+// DefaultConfig returns configuration set with flags registered by
+// RegisterFlags and SLOG_LEVEL, SLOG_TRACE, SLOG_TRACE_FILTER, SLOG_FMT, SLOG_LOG and
+// SLOG_SAMPLE environment variables.
+func DefaultConfig() Config {
+	res := rtConfig
+	res.Filter = DefaultFilter()
+	res.Log = append(make([]string, 0, len(rtConfig.Log)), rtConfig.Log...)
+	return res
+}
+
+// RegisterFlags registers -loglevel, -trace, -trace-filter, -logfmt, -log
+// and -logsample flags with fs for configuring the shared logger, e.g.
+// slog.RegisterFlags(flag.CommandLine). Flags are parsed before the shared
+// logger is first used, and default to the values of SLOG_* environment
+// variables. The package registers no flags by itself.
+func RegisterFlags(fs FlagSet) {
+	rtConfig.RegisterFlags(fs)
+}
+
+// RegisterFlags registers -loglevel, -trace, -trace-filter, -logfmt, -log
+// and -logsample flags with fs. Parsed values are stored in this configuration.
+func (this *Config) RegisterFlags(fs FlagSet) {
+	fs.StringVar(&this.Level, "loglevel", this.Level, "set logging `level`; supported values are \"emergency\", \"alert\", \"critical\", \"error\", \"warn\", \"notice\" and \"info\"")
+	fs.UintVar(&this.Trace, "trace", this.Trace, "enable trace logging with specified `verbosity`")
+	fs.Func("trace-filter", withDefault("only enable trace logging for specified comma-separated `modules`", this.Filter), listSetter(&this.Filter))
+	fs.StringVar(&this.Format, "logfmt", this.Format, "set logging `format`; supported values are \"simple\", \"json\", \"json-pretty\" and layout patterns, e.g. \"%time %level{-7} %msg %fields - %errors\"")
+	fs.Func("log", withDefault("set log output to `destination`, where destination is a comma-separated list of filenames and \"stdout\", \"stderr\" or \"syslog\"", this.Log), listSetter(&this.Log))
+	fs.StringVar(&this.Sample, "logsample", this.Sample, "log records with specified `rates`, e.g. \"trace=0.01,info=0.5\"")
+}
+
+// listSetter returns a flag function that replaces the list with
+// the first occurrence of the flag and appends to it for the subsequent ones.
+func listSetter(list *[]string) func(string) error {
+	set := false
+	return func(s string) error {
+		if !set {
+			*list, set = nil, true
+		}
+		*list = append(*list, splitList(s)...)
+		return nil
+	}
+}
+
+func withDefault(usage string, list []string) string {
+	if len(list) == 0 {
+		return usage
+	}
+	return fmt.Sprintf("%s (default %q)", usage, strings.Join(list, ","))
+}
+
+// LoadConfig reads configuration from a JSON or YAML file.
+// Files with .yaml and .yml extensions are parsed as YAML, all others as JSON.
+func LoadConfig(path string) (Config, error) {
+	var res Config
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return res, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(b, &res)
+	default:
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&res)
+	}
+	if err == nil {
+		res.set, err = configKeys(path, b)
+	}
+	if err == nil {
+		err = res.Validate()
+	}
+	if err != nil {
+		return res, fmt.Errorf("%s: %v", path, err)
+	}
+	return res, nil
+}
+
+// configKeys lists the keys present in configuration file contents b.
+func configKeys(path string, b []byte) (map[string]bool, error) {
+	res := make(map[string]bool)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var m map[string]interface{}
+		if err := yaml.Unmarshal(b, &m); err != nil {
+			return nil, err
+		}
+		for k := range m {
+			res[k] = true
+		}
+	default:
+		var m map[string]json.RawMessage
+		if err := json.Unmarshal(b, &m); err != nil {
+			return nil, err
+		}
+		for k := range m {
+			res[k] = true
+		}
+	}
+	return res, nil
+}
+
+// Validate checks that level, format and destinations are supported.
+func (this Config) Validate() error {
+	if _, err := parseLevel(this.Level, this.Trace); err != nil {
+		return err
+	}
+	if _, err := parseFormat(this.Format); err != nil {
+		return err
+	}
+	if _, err := this.options(); err != nil {
+		return err
+	}
+	for _, dest := range this.Log {
+		if dest == "syslog" && newSyslogFacility == nil {
+			return fmt.Errorf("syslog is not supported on this platform")
+		}
+	}
+	return nil
+}
+
+// merge returns a copy of this configuration with fields of other taking
+// precedence: those present in the file other was loaded from, even if
+// empty or zero, e.g. for resetting trace verbosity with "trace": 0, or
+// non-zero ones if other was not loaded from a file.
+func (this Config) merge(other Config) Config {
+	if other.has("level", len(other.Level) > 0) {
+		this.Level = other.Level
+	}
+	if other.has("trace", other.Trace > 0) {
+		this.Trace = other.Trace
+	}
+	if other.has("filter", len(other.Filter) > 0) {
+		this.Filter = other.Filter
+	}
+	if other.has("format", len(other.Format) > 0) {
+		this.Format = other.Format
+	}
+	if other.has("log", len(other.Log) > 0) {
+		this.Log = other.Log
+	}
+	if other.has("stack", len(other.Stack) > 0) {
+		this.Stack = other.Stack
+	}
+	if other.has("caller", len(other.Caller) > 0) {
+		this.Caller = other.Caller
+	}
+	if other.has("time", len(other.Time) > 0) {
+		this.Time = other.Time
+	}
+	if other.has("time_zone", len(other.TimeZone) > 0) {
+		this.TimeZone = other.TimeZone
+	}
+	if other.has("sample", len(other.Sample) > 0) {
+		this.Sample = other.Sample
+	}
+	return this
+}
+
+func (this Config) has(key string, nonZero bool) bool {
+	if this.set != nil {
+		return this.set[key]
+	}
+	return nonZero
+}
+
+// options returns Logger options for the optional settings.
+func (this Config) options() ([]Option, error) {
+	var res []Option
+	if len(this.Stack) > 0 {
+		pri, err := ParsePriority(this.Stack)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, WithStack(pri))
+	}
+	if len(this.Caller) > 0 {
+		mode, err := ParseCallerMode(this.Caller)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, WithCaller(mode))
+	}
+	if len(this.Time) > 0 || len(this.TimeZone) > 0 {
+		layout := this.Time
+		if len(layout) == 0 {
+			layout = TimeDefault
+		}
+		tf, err := parseTimeFormat(layout, this.TimeZone)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, WithTimeFormat(tf.layout, tf.loc))
+	}
+	if len(this.Sample) > 0 {
+		rates, err := ParseSampling(this.Sample)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, WithSampling(rates))
+	}
+	if strings.Contains(this.Format, "%") {
+		l, err := ParseLayout(this.Format)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, WithLayout(l))
+	}
+	return res, nil
+}
+
+// Configure builds or replaces the shared logger according to cfg.
+// Shared facility is reused if destinations have not changed. Otherwise
+// the previous facility is flushed and left open for logs obtained from
+// the previous shared logger, and its files are closed once no longer
+// referenced. On error the shared logger is left intact.
+//
+// A watched configuration file is applied on top of cfg from then on.
+func Configure(cfg Config) error {
+	if err := configure(cfg); err != nil {
+		return err
+	}
+	sharedLoggerMu.Lock()
+	defer sharedLoggerMu.Unlock()
+	sharedBase = &cfg
+	return nil
+}
+
+func configure(cfg Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	level, _ := parseLevel(cfg.Level, cfg.Trace)
+	formatter, _ := parseFormat(cfg.Format)
+	opts, _ := cfg.options()
+	dests := cfg.Log
+	if len(dests) == 0 {
+		dests = []string{"stderr"}
+	}
+	sharedLoggerMu.Lock()
+	defer sharedLoggerMu.Unlock()
+	sharedFacilityMu.Lock()
+	defer sharedFacilityMu.Unlock()
+	facility := sharedFacility
+	if facility == nil || !equalStrings(dests, sharedLog) {
+		f, err := newFacility(dests, level)
+		if err != nil {
+			return err
+		}
+		facility = f
+	}
+	logger, err := New(facility, level, formatter, cfg.Filter, opts...)
+	if err != nil {
+		if facility != sharedFacility {
+			closeFacilities([]Facility{facility})
+		}
+		return err
+	}
+	if f, ok := sharedFacility.(Flusher); ok && facility != sharedFacility {
+		// Logs obtained earlier still write to the previous facility,
+		// so it is left open until its files are no longer referenced.
+		f.Flush()
+	}
+	sharedFacility, sharedLog, sharedLogger = facility, dests, logger
+	return nil
+}
+
+// WatchConfig applies configuration from the file at path on top of
+// DefaultConfig, or of the one last passed to Configure, to the shared logger, and re-applies it whenever the file
+// changes. The file is checked at the specified interval.
+// Errors loading or applying the configuration are passed to report and
+// leave the working configuration in place. Nil report logs errors with
+// the shared logger. The returned function stops watching.
+//
+// A file named by SLOG_CONFIG environment variable is watched automatically.
+func WatchConfig(path string, interval time.Duration, report func(error)) (stop func()) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	if report == nil {
+		report = reportConfigError
+	}
+	w := &configWatcher{path: path, report: report, done: make(chan struct{})}
+	w.check()
+	go w.run(interval)
+	return w.stop
+}
+
+type configWatcher struct {
+	path    string
+	report  func(error)
+	done    chan struct{}
+	stopped sync.Once
+	modTime time.Time
+	size    int64
+	loaded  bool
+	failure string
+}
+
+func (this *configWatcher) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-this.done:
+			return
+		case <-ticker.C:
+			this.check()
+		}
+	}
+}
+
+func (this *configWatcher) stop() {
+	this.stopped.Do(func() { close(this.done) })
+}
+
+func (this *configWatcher) check() {
+	fi, err := os.Stat(this.path)
+	if err == nil {
+		if this.loaded && fi.ModTime().Equal(this.modTime) && fi.Size() == this.size {
+			return
+		}
+		this.modTime, this.size, this.loaded = fi.ModTime(), fi.Size(), true
+		this.failure = ""
+		var cfg Config
+		if cfg, err = LoadConfig(this.path); err == nil {
+			err = configure(baseConfig().merge(cfg))
+		}
+	}
+	if err == nil {
+		this.failure = ""
+		return
+	}
+	// Report persistent failures only once
+	if err.Error() != this.failure {
+		this.failure = err.Error()
+		this.report(err)
+	}
+}
+
+func baseConfig() Config {
+	sharedLoggerMu.Lock()
+	defer sharedLoggerMu.Unlock()
+	if sharedBase != nil {
+		return *sharedBase
+	}
+	return DefaultConfig()
+}
+
+func reportConfigError(err error) {
+	defaultLogger().On(err).Prints("Unable to apply logging configuration")
+}
+
+func watchSharedConfig() {
+	if len(rtConfigPath) > 0 {
+		WatchConfig(rtConfigPath, DefaultWatchInterval, nil)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,165 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type tcConfig struct {
+	name string
+	body string
+	res  Config
+	fail bool
+}
+
+func TestLoadConfig(tst *testing.T) {
+	dir := tst.TempDir()
+	for _, t := range []tcConfig{
+		{"a.json", `{"level":"warn","format":"json","log":["stdout","stderr"]}`, Config{Level: "warn", Format: "json", Log: []string{"stdout", "stderr"}}, false},
+		{"b.json", `{"trace":2,"filter":["foo.go"]}`, Config{Trace: 2, Filter: []string{"foo.go"}}, false},
+		{"c.json", `{"level":"loud"}`, Config{}, true},
+		{"d.json", `{"colour":"red"}`, Config{}, true},
+		{"e.yaml", "level: notice\nformat: json-pretty\nlog:\n  - stderr\n", Config{Level: "notice", Format: "json-pretty", Log: []string{"stderr"}}, false},
+		{"f.yml", "format: xml\n", Config{}, true},
+	} {
+		path := filepath.Join(dir, t.name)
+		if err := ioutil.WriteFile(path, []byte(t.body), 0600); err != nil {
+			tst.Fatal(err)
+		}
+		res, err := LoadConfig(path)
+		switch {
+		case t.fail && err == nil:
+			tst.Errorf("fail: %s: expected error", t.name)
+		case !t.fail && err != nil:
+			tst.Errorf("fail: %s: unexpected error: %v", t.name, err)
+		case !t.fail && !equalConfigs(t.res, res):
+			tst.Errorf("fail: %s: expected %+v, but had %+v", t.name, t.res, res)
+		}
+	}
+}
+
+func TestWatchConfigKeepsWorkingConfig(tst *testing.T) {
+	defer resetShared()
+	path := filepath.Join(tst.TempDir(), "slog.json")
+	ioutil.WriteFile(path, []byte(`{"level":"warn","log":["stdout"]}`), 0600)
+	var errs []error
+	stop := WatchConfig(path, 0, func(err error) { errs = append(errs, err) })
+	defer stop()
+	if len(errs) > 0 || SharedLogger().Level() != PriorityWarn {
+		tst.Fatalf("fail: configuration not applied: %v", errs)
+	}
+	logger := SharedLogger()
+	ioutil.WriteFile(path, []byte(`{"level":"loud","log":["stdout"]}`), 0600)
+	w := &configWatcher{path: path, report: func(err error) { errs = append(errs, err) }}
+	w.check()
+	if len(errs) != 1 {
+		tst.Errorf("fail: expected validation error to be reported")
+	}
+	if SharedLogger() != logger {
+		tst.Errorf("fail: working configuration dropped")
+	}
+}
+
+func TestMergeConfig(tst *testing.T) {
+	dir := tst.TempDir()
+	base := Config{Level: "warn", Trace: 2, Filter: []string{"a.go"}, Format: "json"}
+	for _, t := range []tcConfig{
+		{"a.json", `{"trace":0,"filter":[]}`, Config{Level: "warn", Filter: []string{}, Format: "json"}, false},
+		{"b.yaml", "level: error\n", Config{Level: "error", Trace: 2, Filter: []string{"a.go"}, Format: "json"}, false},
+	} {
+		path := filepath.Join(dir, t.name)
+		ioutil.WriteFile(path, []byte(t.body), 0600)
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			tst.Fatalf("fail: %s: unexpected error: %v", t.name, err)
+		}
+		if res := base.merge(cfg); !equalConfigs(t.res, res) {
+			tst.Errorf("fail: %s: expected %+v, but had %+v", t.name, t.res, res)
+		}
+	}
+	if res := base.merge(Config{Trace: 0, Level: "info"}); res.Trace != 2 || res.Level != "info" {
+		tst.Errorf("fail: expected zero fields to be kept, but had %+v", res)
+	}
+}
+
+func TestEnvErrors(tst *testing.T) {
+	defer func(errs []error) { envErrors = errs }(envErrors)
+	envErrors = nil
+	tst.Setenv("SLOG_TEST_TRACE", "lots")
+	if n := envUint("SLOG_TEST_TRACE", 3); n != 3 || len(envErrors) != 1 || envErrors[0].Error() != `invalid SLOG_TEST_TRACE: "lots"` {
+		tst.Errorf("fail: expected invalid value to be reported, but had %d, %v", n, envErrors)
+	}
+}
+
+func equalConfigs(a, b Config) bool {
+	return a.Level == b.Level && a.Trace == b.Trace && a.Format == b.Format &&
+		equalStrings(a.Filter, b.Filter) && equalStrings(a.Log, b.Log)
+}
+
+func resetShared() {
+	sharedLoggerMu.Lock()
+	defer sharedLoggerMu.Unlock()
+	sharedFacilityMu.Lock()
+	defer sharedFacilityMu.Unlock()
+	sharedLogger, sharedFacility, sharedLog, sharedBase = nil, nil, nil, nil
+}
+
+func TestRegisterFlags(tst *testing.T) {
+	cfg := Config{Level: "info", Format: "simple", Log: []string{"stderr"}}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg.RegisterFlags(fs)
+	err := fs.Parse([]string{"-loglevel=warn", "-trace-filter=a.go, b.go", "-log=stdout", "-log=x.log", "-logfmt", "json"})
+	if err != nil {
+		tst.Fatal(err)
+	}
+	exp := Config{Level: "warn", Filter: []string{"a.go", "b.go"}, Format: "json", Log: []string{"stdout", "x.log"}}
+	if !equalConfigs(exp, cfg) {
+		tst.Errorf("fail: expected %+v, but had %+v", exp, cfg)
+	}
+}
+
+func TestRegisterSharedFlags(tst *testing.T) {
+	if flag.CommandLine.Lookup("loglevel") != nil {
+		tst.Errorf("fail: expected no flags registered by the package")
+	}
+	defer func(cfg Config) { rtConfig = cfg }(rtConfig)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	RegisterFlags(fs)
+	if err := fs.Parse([]string{"-loglevel=error", "-trace=2"}); err != nil {
+		tst.Fatal(err)
+	}
+	if DefaultLevel() != PriorityTrace+1 || rtConfig.Level != "error" {
+		tst.Errorf("fail: expected flags to configure the shared logger, but had %+v", rtConfig)
+	}
+}
+
+func TestConfigureKeepsPreviousFacility(tst *testing.T) {
+	defer resetShared()
+	dir := tst.TempDir()
+	a, b := filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log")
+	if err := Configure(Config{Level: "info", Log: []string{a}}); err != nil {
+		tst.Fatal(err)
+	}
+	l := SharedLogger().Info()
+	if err := Configure(Config{Level: "info", Log: []string{b}}); err != nil {
+		tst.Fatal(err)
+	}
+	l.Prints("before")
+	SharedLogger().Info().Prints("after")
+	for _, t := range []struct {
+		path string
+		msg  string
+	}{
+		{a, "before"},
+		{b, "after"},
+	} {
+		if out, _ := ioutil.ReadFile(t.path); !strings.Contains(string(out), t.msg) {
+			tst.Errorf("fail: expected %q in %s, but had %q", t.msg, filepath.Base(t.path), out)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"os"
+	"reflect"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Layouter is implemented by facilities that prefer their records to be
+// rendered with a particular layout. Loggers use it unless given a layout
+// of their own with WithLayout, and only with SimpleFormatter, as other
+// formatters produce output meant for machines.
+type Layouter interface {
+	Layout() *Layout
+}
+
+// ConsolePattern is the layout of console facility records. Messages are
+// padded so that fields line up in a column.
+const ConsolePattern = "%time{15:04:05.000} %level{-4,short} %msg{-40} %fields - %errors{indent}"
+
+var consoleLayout, _ = ParseLayout(ConsolePattern)
+
+// ANSI escape sequences.
+const (
+	colorReset   = "\x1b[0m"
+	colorDim     = "\x1b[2m"
+	colorRed     = "\x1b[31m"
+	colorBoldRed = "\x1b[1;31m"
+	colorGreen   = "\x1b[32m"
+	colorYellow  = "\x1b[33m"
+	colorCyan    = "\x1b[36m"
+	colorGray    = "\x1b[90m"
+)
+
+var levelColors = map[Priority]string{
+	PriorityEmergency: colorBoldRed,
+	PriorityAlert:     colorBoldRed,
+	PriorityCritical:  colorBoldRed,
+	PriorityError:     colorRed,
+	PriorityWarn:      colorYellow,
+	PriorityNotice:    colorCyan,
+	PriorityInfo:      colorGreen,
+	PriorityTrace:     colorGray,
+}
+
+type fConsole struct {
+	*fFile
+	layout *Layout
+}
+
+// NewConsoleFacility creates a facility for reading records on a terminal.
+// Its records are rendered with ConsolePattern layout, colorized if file
+// is a terminal or if FORCE_COLOR environment variable is set, unless
+// NO_COLOR environment variable is set.
+func NewConsoleFacility(file *os.File) (Facility, error) {
+	return &fConsole{
+		fFile:  &fFile{file: file},
+		layout: consoleLayout.WithColor(colorEnabled(file)),
+	}, nil
+}
+
+func (this *fConsole) Layout() *Layout {
+	return this.layout
+}
+
+// colorEnabled decides whether to colorize output to file.
+func colorEnabled(file *os.File) bool {
+	if len(os.Getenv("NO_COLOR")) > 0 {
+		return false
+	}
+	return forceColor() || isTerminal(file)
+}
+
+func forceColor() bool {
+	v := os.Getenv("FORCE_COLOR")
+	return len(v) > 0 && v != "0" && v != "false"
+}
+
+// isTerminal reports whether file is a terminal.
+func isTerminal(file *os.File) bool {
+	return term.IsTerminal(int(file.Fd()))
+}
+
+// isSimpleFormatter reports whether f is SimpleFormatter.
+func isSimpleFormatter(f Formatter) bool {
+	return reflect.ValueOf(f).Pointer() == reflect.ValueOf(SimpleFormatter).Pointer()
+}
+
+// WithColor returns a copy of the layout with ANSI colors enabled or
+// disabled. Level is colored according to priority, time and caller are
+// dimmed and errors are red.
+func (this *Layout) WithColor(color bool) *Layout {
+	res := *this
+	res.color = color
+	return &res
+}
+
+// color returns the color of the rendered layout part.
+func (this *layoutPart) color(r *Record) string {
+	switch this.verb {
+	case layoutTime, layoutCaller:
+		return colorDim
+	case layoutLevel:
+		return levelColors[r.Priority.Bound()]
+	case layoutErrors:
+		if len(r.Errors) == 1 && (r.Errors[0] == errSuccess || r.Errors[0] == errEllipsis) {
+			return ""
+		}
+		return colorRed
+	}
+	return ""
+}
+
+// indentLines indents continuation lines of multi-line error messages.
+// Lines that are indented already, such as those of stack traces, are
+// left as they are.
+func indentLines(s string) string {
+	if !strings.Contains(s, "\n") {
+		return s
+	}
+	lines := strings.Split(s, "\n")
+	for i := 1; i < len(lines); i++ {
+		if !strings.HasPrefix(lines[i], "\t") {
+			lines[i] = "\t    " + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,71 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConsole(tst *testing.T) {
+	t1 := time.Date(2016, time.February, 21, 21, 3, 37, 120000000, time.UTC)
+	clock := WithClock(ClockFunc(func() time.Time { return t1 }))
+	f := &tFacility{}
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil, clock, WithTimeFormat("", time.UTC), WithLayout(consoleLayout.WithColor(true)))
+	l.On(errors.New("bad\ninput")).Error().Prints("Failed", "k", 1)
+	exp := "\x1b[2m21:03:37.120\x1b[0m \x1b[31mERRO\x1b[0m Failed" + strings.Repeat(" ", 34) +
+		" k=1 - \x1b[31merror=bad\n\t    input\x1b[0m"
+	if res := f.buf.String(); res != exp+"\n" {
+		tst.Errorf("fail: expected %q, but had %q", exp, res)
+	}
+
+	path := filepath.Join(tst.TempDir(), "console.log")
+	file, err := os.Create(path)
+	if err != nil {
+		tst.Fatal(err)
+	}
+	defer file.Close()
+	for _, t := range []struct {
+		noColor, forceColor string
+		color               bool
+	}{
+		{"", "", false},
+		{"", "1", true},
+		{"1", "1", false},
+	} {
+		tst.Setenv("NO_COLOR", t.noColor)
+		tst.Setenv("FORCE_COLOR", t.forceColor)
+		cf, _ := NewConsoleFacility(file)
+		if cf.(Layouter).Layout().color != t.color {
+			tst.Errorf("fail: expected color %v with NO_COLOR=%q FORCE_COLOR=%q", t.color, t.noColor, t.forceColor)
+		}
+	}
+	if null, err := os.Open(os.DevNull); err == nil {
+		defer null.Close()
+		tst.Setenv("FORCE_COLOR", "1")
+		if isTerminal(null) {
+			tst.Errorf("fail: %s taken for a terminal", os.DevNull)
+		}
+		if f, _ := newStreamFacility(null, true); f == nil {
+			tst.Errorf("fail: expected facility")
+		} else if _, ok := f.(Layouter); ok {
+			tst.Errorf("fail: expected plain facility with FORCE_COLOR")
+		}
+	}
+	cf, _ := NewConsoleFacility(file)
+	l, _ = New(cf, PriorityInfo, CompactJsonFormatter, nil)
+	l.Info().Prints("json", "k", 1)
+	l, _ = New(cf, PriorityInfo, SimpleFormatter, nil)
+	l.Info().Prints("console", "k", 1)
+	b, _ := ioutil.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "INFO ") || !strings.HasSuffix(lines[0], ` json {"k":1}`) ||
+		!strings.Contains(lines[1], " INFO console ") {
+		tst.Errorf("fail: unexpected output %q", lines)
+	}
+}
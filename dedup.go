@@ -0,0 +1,142 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit on the number of distinct records tracked for duplicates.
+// Records beyond it are not suppressed.
+const maxDedupEntries = 10000
+
+type dedupEntry struct {
+	log    *sLog
+	logger *log.Logger
+	rec    *Record
+	count  int
+	first  time.Time
+	last   time.Time
+	timer  *time.Timer
+}
+
+type dedup struct {
+	window   time.Duration
+	volatile map[string]bool
+	mux      sync.Mutex
+	entries  map[string]*dedupEntry
+}
+
+// WithDedup makes the logger suppress records identical to one logged
+// within the window before. Records are identical if they have the same
+// priority, call site, message, errors and values, except for the values
+// of volatile top level keys. The first record is logged right away.
+// Its duplicates are collapsed into a summary record logged at the end
+// of the window, which repeats the first record with "repeated" count
+// and "first_seen" and "last_seen" timestamps of the duplicates added,
+// and reports the call site of the first record. Flush logs pending
+// summaries.
+func WithDedup(window time.Duration, volatile ...string) Option {
+	return func(l *sLogger) {
+		d := &dedup{window: window, volatile: make(map[string]bool), entries: make(map[string]*dedupEntry)}
+		for _, k := range volatile {
+			d.volatile[k] = true
+		}
+		l.dedup = d
+	}
+}
+
+// admit reports whether the record is to be logged, or is a duplicate.
+// The log l is bound to the call site of the record.
+func (this *dedup) admit(l *sLog, logger *log.Logger, rec *Record) bool {
+	var site string
+	if l.site != nil {
+		site = l.site.File + ":" + strconv.Itoa(l.site.Line)
+	}
+	key := this.key(site, rec)
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if e, ok := this.entries[key]; ok {
+		if e.count == 0 {
+			e.first = rec.Time
+		}
+		e.count++
+		e.last = rec.Time
+		return false
+	}
+	if len(this.entries) < maxDedupEntries {
+		e := &dedupEntry{log: l, logger: logger, rec: rec}
+		e.timer = time.AfterFunc(this.window, func() { this.expire(key, e) })
+		this.entries[key] = e
+	}
+	return true
+}
+
+// expire ends the window of the entry, logging the summary of its
+// duplicates if there are any.
+func (this *dedup) expire(key string, e *dedupEntry) {
+	this.mux.Lock()
+	if this.entries[key] != e {
+		this.mux.Unlock()
+		return
+	}
+	delete(this.entries, key)
+	this.mux.Unlock()
+	e.summarize()
+}
+
+func (this *dedupEntry) summarize() {
+	if this.count == 0 {
+		return
+	}
+	rec := *this.rec
+	rec.Time = this.log.clock.Now()
+	rec.Add("repeated", this.count, "first_seen", this.first, "last_seen", this.last)
+	this.log.emit(this.logger, 1, &rec)
+}
+
+// flush logs summaries of all pending duplicates.
+func (this *dedup) flush() {
+	this.mux.Lock()
+	es := make([]*dedupEntry, 0, len(this.entries))
+	for k, e := range this.entries {
+		e.timer.Stop()
+		es = append(es, e)
+		delete(this.entries, k)
+	}
+	this.mux.Unlock()
+	for _, e := range es {
+		e.summarize()
+	}
+}
+
+func (this *dedup) key(site string, rec *Record) string {
+	var b strings.Builder
+	b.WriteString(strconv.Itoa(int(rec.Priority)))
+	b.WriteByte(0)
+	b.WriteString(site)
+	b.WriteByte(0)
+	b.WriteString(rec.Message)
+	for _, e := range rec.Errors {
+		b.WriteByte(0)
+		if e != nil {
+			b.WriteString(e.Error())
+		}
+	}
+	for i := 0; i < len(rec.Values); i += 2 {
+		if k, ok := rec.Values[i].(string); ok && this.volatile[k] {
+			continue
+		}
+		b.WriteByte(0)
+		b.WriteString(asString(rec.Values[i]))
+		if i+1 < len(rec.Values) {
+			b.WriteByte('=')
+			b.WriteString(asString(rec.Values[i+1]))
+		}
+	}
+	return b.String()
+}
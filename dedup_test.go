@@ -0,0 +1,66 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDedup(tst *testing.T) {
+	f := &tFacility{}
+	n := 0
+	clock := WithClock(ClockFunc(func() time.Time {
+		n++
+		return time.Date(2016, time.February, 21, 21, 3, n, 0, time.UTC)
+	}))
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil, clock, WithDedup(time.Hour, "attempt"))
+	err := errors.New("connection refused")
+	for i := 0; i < 4; i++ {
+		l.On(err).Error().Prints("Unable to connect", "attempt", i)
+	}
+	l.On(err).Error().Prints("Unable to connect", "attempt", 9)
+	l.Info().Prints("other")
+	l.Flush()
+	exp := []string{
+		"ERROR Unable to connect attempt=0 - error=connection refused",
+		"ERROR Unable to connect attempt=9 - error=connection refused",
+		"INFO other",
+		"ERROR Unable to connect attempt=0 repeated=3 first_seen=2016-02-21T21:03:02Z last_seen=2016-02-21T21:03:04Z - error=connection refused",
+	}
+	if res := f.lines(); strings.Join(res, "|") != strings.Join(exp, "|") {
+		tst.Errorf("fail: expected %q, but had %q", exp, res)
+	}
+
+	l, _ = New(f, PriorityInfo, SimpleFormatter, nil, WithDedup(10*time.Millisecond))
+	for i := 0; i < 3; i++ {
+		l.Info().Prints("tick")
+	}
+	time.Sleep(50 * time.Millisecond)
+	l.Info().Prints("tick")
+	res := f.lines()
+	if len(res) != 3 || res[0] != "INFO tick" || !strings.HasPrefix(res[1], "INFO tick repeated=2 ") || res[2] != "INFO tick" {
+		tst.Errorf("fail: unexpected output %q", res)
+	}
+}
+
+func TestDedupSummary(tst *testing.T) {
+	f := &tFacility{}
+	layout, _ := ParseLayout("%level %msg [%caller] %fields")
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil, WithLayout(layout), WithDedup(time.Hour, "n"))
+	m := map[string]int{"a": 1}
+	var line int
+	for i := 0; i < 3; i++ {
+		line = lineOf(func() { l.Info().Prints("tick", "m", m, "n", i) })
+	}
+	m["a"] = 5
+	l.Flush()
+	res := f.lines()
+	exp := fmt.Sprintf("INFO tick [dedup_test.go:%d] m.a=1 n=0 repeated=2 ", line)
+	if len(res) != 2 || !strings.HasPrefix(res[1], exp) {
+		tst.Errorf("fail: expected %q, but had %q", exp, res)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LogMarshaler is implemented by types that control their representation
+// in records. MarshalLog returns a format independent value: a primitive,
+// a string, Fields, or a slice or a map of such values. Unlike LogValuer,
+// it is applied to values at any depth. It is called when the record is
+// logged, ahead of redaction.
+type LogMarshaler interface {
+	MarshalLog() interface{}
+}
+
+// How byte slices are rendered.
+type BytesEncoding int32
+
+const (
+	BytesBase64 BytesEncoding = iota
+	BytesHex
+)
+
+var bytesEncoding int32
+
+// SetBytesEncoding sets how byte slices are rendered by all formatters.
+// Default is BytesBase64.
+func SetBytesEncoding(enc BytesEncoding) {
+	atomic.StoreInt32(&bytesEncoding, int32(enc))
+}
+
+var encoders sync.Map
+
+// RegisterEncoder sets the function that produces the representation of
+// values of the same type as sample, for types that cannot implement
+// LogMarshaler. The function is given values of that type.
+func RegisterEncoder(sample interface{}, fn func(interface{}) interface{}) {
+	encoders.Store(reflect.TypeOf(sample), fn)
+}
+
+// Limit on nesting of values being encoded.
+const maxEncodeDepth = 16
+
+// encodeValue returns format independent representation of a value.
+// The same policies apply to all formatters:
+//   - registered encoders and LogMarshalers come first;
+//   - errors render as their messages, durations as their String form
+//     and times in RFC 3339 format with nanoseconds;
+//   - byte slices render as base64 or hex strings;
+//   - other Stringers render as their String form;
+//   - nil pointers and interfaces render as nil, other pointers as
+//     the values they point to;
+//   - maps and structs render as Fields, structs according to their
+//     log tags, and slices as slices of encoded values;
+//   - functions and channels render as their types.
+func encodeValue(value interface{}) interface{} {
+	return encodeDepth(value, 0, false)
+}
+
+// encodeValues encodes values of a record as it is logged, so that the
+// record does not share maps, structs or pointers with the caller.
+// The argument redactors leaves Redactors at any depth in place for
+// redaction to find.
+func encodeValues(v []interface{}, redactors bool) []interface{} {
+	res := make([]interface{}, len(v))
+	for i := range v {
+		res[i] = encodeDepth(v[i], 0, redactors)
+	}
+	return res
+}
+
+func encodeDepth(value interface{}, depth int, redactors bool) interface{} {
+	if value == nil || depth > maxEncodeDepth {
+		return value
+	}
+	if _, ok := value.(Redactor); ok && redactors {
+		return value
+	}
+	if fn, ok := encoders.Load(reflect.TypeOf(value)); ok {
+		return encodeDepth(fn.(func(interface{}) interface{})(value), depth+1, redactors)
+	}
+	switch v := value.(type) {
+	case LogMarshaler:
+		return encodeDepth(v.MarshalLog(), depth+1, redactors)
+	case Stack:
+		// Renders itself one frame per line in text and as array in JSON
+		return v
+	case Fields:
+		res := make(Fields, len(v))
+		for i := range v {
+			res[i] = encodeDepth(v[i], depth+1, redactors)
+		}
+		return res
+	case string, bool, int, int64, int32, uint, uint64, uint32, float64, float32:
+		return v
+	case error:
+		if isNilPointer(v) {
+			return nil
+		}
+		return v.Error()
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		if BytesEncoding(atomic.LoadInt32(&bytesEncoding)) == BytesHex {
+			return hex.EncodeToString(v)
+		}
+		return base64.StdEncoding.EncodeToString(v)
+	case fmt.Stringer:
+		if isNilPointer(v) {
+			return nil
+		}
+		return v.String()
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return encodeDepth(rv.Elem().Interface(), depth+1, redactors)
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil
+		}
+		return mapValues(rv, depth, redactors)
+	case reflect.Struct:
+		return structValues(rv, depth, redactors)
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil
+		}
+		res := make([]interface{}, rv.Len())
+		for i := range res {
+			res[i] = encodeDepth(rv.Index(i).Interface(), depth+1, redactors)
+		}
+		return res
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return fmt.Sprintf("%T", value)
+	}
+	return value
+}
+
+func isNilPointer(value interface{}) bool {
+	rv := reflect.ValueOf(value)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// textValue renders encoded value in text formats.
+func textValue(value interface{}) string {
+	if value == nil {
+		return "<nil>"
+	}
+	switch v := value.(type) {
+	case string:
+		return v
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i := range v {
+			parts[i] = textValue(v[i])
+		}
+		return "[" + strings.Join(parts, " ") + "]"
+	case Fields:
+		buf := make([]string, 0, len(v)/2+1)
+		for i := 0; i < len(v); i += 2 {
+			if i+1 < len(v) {
+				buf = append(buf, textValue(v[i])+"="+textValue(v[i+1]))
+			} else {
+				buf = append(buf, textValue(v[i]))
+			}
+		}
+		return "{" + strings.Join(buf, " ") + "}"
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprintf("%+v", value)
+}
+
+func (this Fields) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonValues(this))
+}
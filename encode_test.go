@@ -0,0 +1,75 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type tMarshaler struct {
+	id     int
+	secret string
+}
+
+func (this tMarshaler) MarshalLog() interface{} {
+	return Fields{"id", this.id}
+}
+
+type tEncoded struct {
+	a, b int
+}
+
+type tNilError struct {
+	msg string
+}
+
+func (this *tNilError) Error() string {
+	return this.msg
+}
+
+func TestValueEncoding(tst *testing.T) {
+	RegisterEncoder(tEncoded{}, func(v interface{}) interface{} {
+		e := v.(tEncoded)
+		return []int{e.a, e.b}
+	})
+	defer encoders.Delete(reflect.TypeOf(tEncoded{}))
+	var np *int
+	n := 5
+	var ne error
+	var npe *tNilError
+	for _, t := range []struct {
+		v    interface{}
+		text string
+		json string
+	}{
+		{1500 * time.Millisecond, "v=1.5s", `"1.5s"`},
+		{[]byte{1, 2, 255}, "v=AQL/", `"AQL/"`},
+		{map[int]string{2: "b", 1: "a"}, "v.1=a v.2=b", `{"1":"a","2":"b"}`},
+		{[]map[string]int{{"a": 1}}, "v=[{a=1}]", `[{"a":1}]`},
+		{[]interface{}{1, "x", 2 * time.Second}, "v=[1 x 2s]", `[1,"x","2s"]`},
+		{[2]bool{true, false}, "v=[true false]", `[true,false]`},
+		{np, "v=<nil>", `null`},
+		{&n, "v=5", `5`},
+		{ne, "v=<nil>", `null`},
+		{npe, "v=<nil>", `null`},
+		{func() {}, "v=func()", `"func()"`},
+		{tMarshaler{id: 7, secret: "pwd"}, "v.id=7", `{"id":7}`},
+		{[]tMarshaler{{id: 7}}, "v=[{id=7}]", `[{"id":7}]`},
+		{tEncoded{1, 2}, "v=[1 2]", `[1,2]`},
+	} {
+		if res := SimpleFormatter("", []interface{}{"v", t.v}, nil); res != t.text {
+			tst.Errorf("fail: expected %q, but had %q", t.text, res)
+		}
+		json := `{"v":` + t.json + `}`
+		if res := CompactJsonFormatter("", []interface{}{"v", t.v}, nil); res != json {
+			tst.Errorf("fail: expected %q, but had %q", json, res)
+		}
+	}
+	SetBytesEncoding(BytesHex)
+	defer SetBytesEncoding(BytesBase64)
+	if res := SimpleFormatter("", []interface{}{"v", []byte{1, 255}}, nil); res != "v=01ff" {
+		tst.Errorf("fail: expected \"v=01ff\", but had %q", res)
+	}
+}
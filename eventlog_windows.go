@@ -9,7 +9,7 @@ import (
 
 func (this Priority) EventlogPriority() uint16 {
 	switch this.Bound() {
-	case PriorityError:
+	case PriorityEmergency, PriorityAlert, PriorityCritical, PriorityError:
 		return windows.EVENTLOG_ERROR_TYPE
 	case PriorityWarn:
 		return windows.EVENTLOG_WARNING_TYPE
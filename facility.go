@@ -4,9 +4,11 @@ package slog
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"sync"
+	"time"
 )
 
 type Facility interface {
@@ -15,34 +17,124 @@ type Facility interface {
 }
 
 type fFile struct {
-	path string
-	mux  sync.RWMutex
-	file *os.File
+	path         string
+	mux          sync.Mutex
+	file         *os.File
+	syncEvery    int
+	syncInterval time.Duration
+	syncPri      Priority
+	syncOnPri    bool
+	dsync        bool
+	lock         bool
+	bufSize      int
+	latency      time.Duration
+	buf          []byte
+	buffered     int
+	unsynced     int
+	flushTimer   *time.Timer
+	syncTimer    *time.Timer
+	failed       failedFunc
 }
 
-func NewStdFacility(file *os.File) (Facility, error) {
-	return &fFile{file: file}, nil
+// FileOption configures durability of file facilities.
+//
+// Each record is passed to the file in a single write, whole, and
+// buffered records are written in batches of whole records. Regular files
+// on local file systems opened for appending do not interleave such
+// writes of several processes. Pipes keep them whole only up to PIPE_BUF
+// bytes, and network file systems need LockWrites.
+//
+// Failures of writes and commits deferred by BufferWrites and
+// SyncInterval are reported to the write error handler and counted in
+// metrics of the logger that opened the facility.
+type FileOption func(*fFile)
+
+// SyncEvery makes the facility commit the file to stable storage after
+// every n records, e.g. after each record with n of 1.
+func SyncEvery(n int) FileOption {
+	return func(f *fFile) {
+		f.syncEvery = n
+	}
+}
+
+// SyncInterval makes the facility commit the file to stable storage no
+// later than interval d after a record is written.
+func SyncInterval(d time.Duration) FileOption {
+	return func(f *fFile) {
+		f.syncInterval = d
+	}
+}
+
+// SyncPriority makes the facility commit the file to stable storage right
+// after each record of priority pri or more severe, e.g. PriorityError.
+func SyncPriority(pri Priority) FileOption {
+	return func(f *fFile) {
+		f.syncPri, f.syncOnPri = pri.Bound(), true
+	}
+}
+
+// Dsync makes the facility open the file in O_DSYNC mode, so that each
+// write returns after the data reaches stable storage. Where O_DSYNC is
+// not available, O_SYNC is used.
+func Dsync() FileOption {
+	return func(f *fFile) {
+		f.dsync = true
+	}
 }
 
-func NewFileFacility(path string) (Facility, error) {
-	return &fFile{path: path}, nil
+// LockWrites makes the facility hold an advisory lock on the file for
+// each write, for processes appending to the same file on file systems
+// that do not append atomically, such as NFS. It has effect only where
+// flock is available.
+func LockWrites() FileOption {
+	return func(f *fFile) {
+		f.lock = true
+	}
+}
+
+// BufferWrites makes the facility collect records in an in-process buffer
+// of size bytes and write them out when the buffer fills up, no later than
+// latency after the first record in the buffer, before commits to stable
+// storage, and on Flush, Reopen and Close. Records larger than the buffer
+// are written directly.
+func BufferWrites(size int, latency time.Duration) FileOption {
+	return func(f *fFile) {
+		f.bufSize, f.latency = size, latency
+	}
+}
+
+func NewStdFacility(file *os.File, opts ...FileOption) (Facility, error) {
+	return newFileFacility(&fFile{file: file}, opts), nil
+}
+
+func NewFileFacility(path string, opts ...FileOption) (Facility, error) {
+	return newFileFacility(&fFile{path: path}, opts), nil
+}
+
+func newFileFacility(f *fFile, opts []FileOption) *fFile {
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
 }
 
 func (this *fFile) OpenLogs(level Priority) (map[Priority]*log.Logger, error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
 	var err error
 	if this.file == nil && len(this.path) > 0 {
-		this.file, err = os.OpenFile(this.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0640)
+		this.file, err = this.open()
 		if err != nil {
 			return nil, err
 		}
 	}
 	res := make(map[Priority]*log.Logger, prioritiesCount)
-	for pri := PriorityError; pri <= PriorityTrace; pri++ {
+	for pri := PriorityEmergency; pri <= PriorityTrace; pri++ {
 		if pri <= level {
 			if pri < PriorityTrace {
-				res[pri] = log.New(this, pri.Tag(), log.Ldate|log.Ltime)
+				res[pri] = log.New(&fileWriter{this, pri}, pri.Tag(), log.Ldate|log.Ltime)
 			} else {
-				res[pri] = log.New(this, pri.Tag(), log.Ldate|log.Ltime|log.Lshortfile)
+				res[pri] = log.New(&fileWriter{this, pri}, pri.Tag(), log.Ldate|log.Ltime|log.Lshortfile)
 			}
 		} else {
 			res[pri] = drain.Logger()
@@ -51,6 +143,14 @@ func (this *fFile) OpenLogs(level Priority) (map[Priority]*log.Logger, error) {
 	return res, nil
 }
 
+func (this *fFile) open() (*os.File, error) {
+	flags := os.O_RDWR | os.O_CREATE | os.O_APPEND
+	if this.dsync {
+		flags |= oDsync
+	}
+	return os.OpenFile(this.path, flags, 0640)
+}
+
 func (this *fFile) Reopen() error {
 	this.mux.Lock()
 	defer this.mux.Unlock()
@@ -58,23 +158,286 @@ func (this *fFile) Reopen() error {
 		if this.file == nil {
 			return fmt.Errorf("unable to reopen file: %s", this.path)
 		}
-		if f, err := os.OpenFile(this.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0640); err != nil {
+		if f, err := this.open(); err != nil {
 			return err
 		} else {
+			this.flushBuffer()
 			this.file.Sync()
 			this.file.Close()
 			this.file = f
+			this.unsynced = 0
 		}
 	}
 	return nil
 }
 
+// Flush writes out buffered records and commits the log file to stable
+// storage.
+func (this *fFile) Flush() error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.file == nil {
+		return nil
+	}
+	if len(this.path) == 0 {
+		return this.flushBuffer()
+	}
+	return this.sync()
+}
+
+// Close closes the log file, writing out buffered records. Standard
+// streams are left open.
+func (this *fFile) Close() error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.stopTimers()
+	if this.file == nil {
+		return nil
+	}
+	err := this.flushBuffer()
+	if len(this.path) == 0 {
+		return err
+	}
+	if this.syncEvery > 0 || this.syncInterval > 0 || this.syncOnPri {
+		if err2 := this.file.Sync(); err == nil {
+			err = err2
+		}
+	}
+	if err2 := this.file.Close(); err == nil {
+		err = err2
+	}
+	this.file = nil
+	return err
+}
+
 func (this *fFile) Write(p []byte) (n int, err error) {
-	this.mux.RLock()
-	defer this.mux.RUnlock()
+	return this.write(PriorityTrace, p)
+}
+
+// fileWriter writes records of priority pri to the file facility.
+type fileWriter struct {
+	file *fFile
+	pri  Priority
+}
+
+func (this *fileWriter) Write(p []byte) (int, error) {
+	return this.file.write(this.pri, p)
+}
+
+func (this *fFile) write(pri Priority, p []byte) (int, error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.file == nil {
+		return 0, fmt.Errorf("not open: %s", this.path)
+	}
+	var err error
+	if len(this.buf)+len(p) > this.bufSize {
+		err = this.flushBuffer()
+	}
+	if err == nil {
+		if len(p) <= this.bufSize {
+			if len(this.buf) == 0 && this.latency > 0 {
+				this.flushTimer = time.AfterFunc(this.latency, this.flushLater)
+			}
+			this.buf = append(this.buf, p...)
+			this.buffered++
+		} else {
+			err = this.writeFile(p)
+		}
+	}
+	this.unsynced++
+	if err == nil && len(this.path) > 0 {
+		if (this.syncOnPri && pri <= this.syncPri) || (this.syncEvery > 0 && this.unsynced >= this.syncEvery) {
+			err = this.sync()
+		} else if this.syncInterval > 0 && this.syncTimer == nil {
+			this.syncTimer = time.AfterFunc(this.syncInterval, this.syncLater)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeFile writes p to the file in a single write.
+func (this *fFile) writeFile(p []byte) error {
+	if this.lock {
+		lockFile(this.file)
+		defer unlockFile(this.file)
+	}
+	_, err := this.file.Write(p)
+	return err
+}
+
+func (this *fFile) flushBuffer() error {
+	if this.flushTimer != nil {
+		this.flushTimer.Stop()
+		this.flushTimer = nil
+	}
+	if len(this.buf) == 0 {
+		return nil
+	}
+	err := this.writeFile(this.buf)
+	this.buf, this.buffered = this.buf[:0], 0
+	return err
+}
+
+// sync writes out buffered records and commits the file to stable
+// storage.
+func (this *fFile) sync() error {
+	if this.syncTimer != nil {
+		this.syncTimer.Stop()
+		this.syncTimer = nil
+	}
+	err := this.flushBuffer()
+	if err2 := this.file.Sync(); err == nil {
+		err = err2
+	}
+	this.unsynced = 0
+	return err
+}
+
+func (this *fFile) flushLater() {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.flushTimer = nil
 	if this.file != nil {
-		return this.file.Write(p)
+		n := this.buffered
+		if err := this.flushBuffer(); err != nil {
+			this.report(err, n)
+		}
+	}
+}
+
+func (this *fFile) syncLater() {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.syncTimer = nil
+	if this.file != nil && this.unsynced > 0 {
+		n := this.unsynced
+		if err := this.sync(); err != nil {
+			this.report(err, n)
+		}
+	}
+}
+
+// report reports failure of a deferred write or commit of n records.
+func (this *fFile) report(err error, n int) {
+	if this.failed != nil {
+		this.failed(err, n)
 	} else {
-		return 0, fmt.Errorf("not open: %s", this.path)
+		defaultWriteErrors().failed(err, uint64(n))
+	}
+}
+
+func (this *fFile) setFailed(fn failedFunc) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.failed = fn
+}
+
+func (this *fFile) stopTimers() {
+	for _, t := range []*time.Timer{this.flushTimer, this.syncTimer} {
+		if t != nil {
+			t.Stop()
+		}
+	}
+	this.flushTimer, this.syncTimer = nil, nil
+}
+
+type fTee struct {
+	facilities []Facility
+}
+
+// NewTeeFacility creates a facility that duplicates records to all of the
+// specified facilities. Line headers follow the settings of the first
+// facility that has a given priority enabled.
+func NewTeeFacility(facilities ...Facility) (Facility, error) {
+	if len(facilities) == 0 {
+		return nil, errNoFacility
+	}
+	return &fTee{facilities: facilities}, nil
+}
+
+func (this *fTee) setNotify(fn notifyFunc) {
+	for _, f := range this.facilities {
+		if n, ok := f.(notifier); ok {
+			n.setNotify(fn)
+		}
+	}
+}
+
+func (this *fTee) setFailed(fn failedFunc) {
+	for _, f := range this.facilities {
+		if r, ok := f.(failureReporter); ok {
+			r.setFailed(fn)
+		}
+	}
+}
+
+func (this *fTee) OpenLogs(level Priority) (map[Priority]*log.Logger, error) {
+	first := make(map[Priority]*log.Logger, prioritiesCount)
+	writers := make(map[Priority][]io.Writer, prioritiesCount)
+	for _, f := range this.facilities {
+		ls, err := f.OpenLogs(level)
+		if err != nil {
+			return nil, err
+		}
+		for pri, l := range ls {
+			if l == dscrd {
+				continue
+			}
+			if first[pri] == nil {
+				first[pri] = l
+			}
+			writers[pri] = append(writers[pri], l.Writer())
+		}
+	}
+	res := make(map[Priority]*log.Logger, prioritiesCount)
+	for pri := PriorityEmergency; pri <= PriorityTrace; pri++ {
+		if l := first[pri]; l != nil {
+			res[pri] = log.New(io.MultiWriter(writers[pri]...), l.Prefix(), l.Flags())
+		} else {
+			res[pri] = drain.Logger()
+		}
+	}
+	return res, nil
+}
+
+func (this *fTee) Reopen() error {
+	var res error
+	for _, f := range this.facilities {
+		if err := f.Reopen(); err != nil && res == nil {
+			res = err
+		}
+	}
+	return res
+}
+
+func (this *fTee) Flush() error {
+	var res error
+	for _, f := range this.facilities {
+		if fl, ok := f.(Flusher); ok {
+			if err := fl.Flush(); err != nil && res == nil {
+				res = err
+			}
+		}
+	}
+	return res
+}
+
+func (this *fTee) Close() error {
+	return closeFacilities(this.facilities)
+}
+
+func closeFacilities(facilities []Facility) error {
+	var res error
+	for _, f := range facilities {
+		if c, ok := f.(io.Closer); ok {
+			if err := c.Close(); err != nil && res == nil {
+				res = err
+			}
+		}
 	}
+	return res
 }
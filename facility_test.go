@@ -0,0 +1,120 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileDurability(tst *testing.T) {
+	dir := tst.TempDir()
+	read := func(path string) []string {
+		b, _ := ioutil.ReadFile(path)
+		s := strings.TrimSuffix(string(b), "\n")
+		if len(s) == 0 {
+			return nil
+		}
+		return strings.Split(s, "\n")
+	}
+	for i, t := range []struct {
+		opts  []FileOption
+		log   func(l Logger)
+		wait  time.Duration
+		lines int
+	}{
+		{[]FileOption{BufferWrites(1024, time.Hour)}, func(l Logger) { l.Info().Prints("a"); l.Info().Prints("b") }, 0, 0},
+		{[]FileOption{BufferWrites(1024, 10*time.Millisecond)}, func(l Logger) { l.Info().Prints("a") }, 100 * time.Millisecond, 1},
+		{[]FileOption{BufferWrites(1024, time.Hour), SyncPriority(PriorityError)}, func(l Logger) { l.Info().Prints("a"); l.Error().Prints("b") }, 0, 2},
+		{[]FileOption{BufferWrites(1024, time.Hour), SyncEvery(2)}, func(l Logger) { l.Info().Prints("a"); l.Info().Prints("b"); l.Info().Prints("c") }, 0, 2},
+		{[]FileOption{BufferWrites(16, time.Hour)}, func(l Logger) { l.Info().Prints("a"); l.Info().Prints(strings.Repeat("x", 32)) }, 0, 2},
+		{[]FileOption{Dsync(), SyncInterval(time.Millisecond)}, func(l Logger) { l.Info().Prints("a") }, 0, 1},
+	} {
+		path := filepath.Join(dir, "test.log")
+		f, _ := NewFileFacility(path, t.opts...)
+		l, err := New(f, PriorityInfo, SimpleFormatter, nil, WithWriteErrorHandler(nil, 0))
+		if err != nil {
+			tst.Fatalf("fail: unable to open file: %v", err)
+		}
+		t.log(l)
+		time.Sleep(t.wait)
+		if res := read(path); len(res) != t.lines {
+			tst.Errorf("fail %d: expected %d lines, but had %q", i, t.lines, res)
+		}
+		l.Flush()
+		f.(io.Closer).Close()
+		res := read(path)
+		for _, s := range res {
+			if !strings.HasPrefix(s, "INFO ") && !strings.HasPrefix(s, "ERROR ") {
+				tst.Errorf("fail %d: unexpected line %q", i, s)
+			}
+		}
+		l.Info().Prints("closed")
+		if len(read(path)) != len(res) {
+			tst.Errorf("fail %d: expected no output after close", i)
+		}
+		ioutil.WriteFile(path, nil, 0640)
+	}
+}
+
+func TestFileRecordsNotSplit(tst *testing.T) {
+	path := filepath.Join(tst.TempDir(), "test.log")
+	long := strings.Repeat("x", 64<<10)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		f, _ := NewFileFacility(path, LockWrites(), BufferWrites(256<<10, time.Millisecond))
+		l, _ := New(f, PriorityInfo, SimpleFormatter, nil)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				l.Info().Prints(long)
+			}
+			f.(io.Closer).Close()
+		}()
+	}
+	wg.Wait()
+	b, _ := ioutil.ReadFile(path)
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	if len(lines) != 80 {
+		tst.Fatalf("fail: expected 80 records, but had %d", len(lines))
+	}
+	for _, s := range lines {
+		if !strings.HasPrefix(s, "INFO ") || !strings.HasSuffix(s, " "+long) {
+			tst.Errorf("fail: split record of length %d", len(s))
+		}
+	}
+}
+
+func TestFileDeferredErrors(tst *testing.T) {
+	path := filepath.Join(tst.TempDir(), "test.log")
+	f, _ := NewFileFacility(path, BufferWrites(1024, 10*time.Millisecond))
+	m := &Metrics{name: "deferred"}
+	failed := make(chan uint64, 1)
+	l, err := New(f, PriorityInfo, SimpleFormatter, nil, WithMetrics(m), WithWriteErrorHandler(func(err error, n uint64) { failed <- n }, 0))
+	if err != nil {
+		tst.Fatalf("fail: unable to open file: %v", err)
+	}
+	ff := f.(*fFile)
+	ff.mux.Lock()
+	ff.file.Close()
+	ff.mux.Unlock()
+	l.Info().Prints("a")
+	l.Info().Prints("b")
+	select {
+	case n := <-failed:
+		if n != 2 {
+			tst.Errorf("fail: expected 2 failed records, but had %d", n)
+		}
+	case <-time.After(time.Second):
+		tst.Fatalf("fail: expected deferred write error to be reported")
+	}
+	if n := m.WriteErrors(); n != 2 {
+		tst.Errorf("fail: expected 2 write errors, but had %d", n)
+	}
+}
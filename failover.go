@@ -0,0 +1,333 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// WriteErrorHandler is called when records fail to be written with
+// the error and the number of failures since the previous call.
+type WriteErrorHandler func(err error, failures uint64)
+
+// Default interval between write error handler calls.
+const DefaultWriteErrorInterval = 10 * time.Second
+
+// writeErrorLimiter rate limits write error handler calls.
+type writeErrorLimiter struct {
+	handler  WriteErrorHandler
+	interval time.Duration
+	mux      sync.Mutex
+	last     time.Time
+	failures uint64
+}
+
+func newWriteErrorLimiter(h WriteErrorHandler, interval time.Duration) *writeErrorLimiter {
+	return &writeErrorLimiter{handler: h, interval: interval}
+}
+
+func (this *writeErrorLimiter) failed(err error, records uint64) {
+	if this == nil || this.handler == nil {
+		return
+	}
+	this.mux.Lock()
+	this.failures += records
+	now := time.Now()
+	if !this.last.IsZero() && now.Sub(this.last) < this.interval {
+		this.mux.Unlock()
+		return
+	}
+	n := this.failures
+	this.last, this.failures = now, 0
+	this.mux.Unlock()
+	this.handler(err, n)
+}
+
+// stderrWriteErrorHandler reports write errors to standard error.
+func stderrWriteErrorHandler(err error, failures uint64) {
+	fmt.Fprintf(os.Stderr, "slog: %d record(s) failed to be written: %v\n", failures, err)
+}
+
+var (
+	writeErrorHandlerMu sync.RWMutex
+	writeErrorHandler   = newWriteErrorLimiter(stderrWriteErrorHandler, DefaultWriteErrorInterval)
+)
+
+// SetWriteErrorHandler sets handler of write failures for loggers that
+// were not given one of their own with WithWriteErrorHandler option.
+// The handler is called at most once per interval. Nil handler ignores
+// failures. By default failures are reported to standard error.
+func SetWriteErrorHandler(h WriteErrorHandler, interval time.Duration) {
+	writeErrorHandlerMu.Lock()
+	defer writeErrorHandlerMu.Unlock()
+	writeErrorHandler = newWriteErrorLimiter(h, interval)
+}
+
+func defaultWriteErrors() *writeErrorLimiter {
+	writeErrorHandlerMu.RLock()
+	defer writeErrorHandlerMu.RUnlock()
+	return writeErrorHandler
+}
+
+// WithWriteErrorHandler makes the logger report write failures to h,
+// at most once per interval, instead of the default handler.
+func WithWriteErrorHandler(h WriteErrorHandler, interval time.Duration) Option {
+	return func(l *sLogger) {
+		l.writeErrors = newWriteErrorLimiter(h, interval)
+	}
+}
+
+type fFailover struct {
+	primary   Facility
+	secondary Facility
+	threshold int
+	probe     time.Duration
+	mux       sync.Mutex
+	logs      [2]map[Priority]*log.Logger
+	active    int
+	failures  int
+	probed    time.Time
+	notify    notifyFunc
+	noticeMu  sync.Mutex
+	noticed   *sync.Cond
+	notices   []func()
+	sending   bool
+}
+
+// notifyFunc logs a record on behalf of a facility.
+type notifyFunc func(pri Priority, message string, v []interface{}, err error)
+
+// notifier is implemented by facilities that report events with records
+// of the logger that opened them, so that the records are rendered as
+// the logger's own.
+type notifier interface {
+	setNotify(fn notifyFunc)
+}
+
+// failedFunc reports failure of a deferred write of n records.
+type failedFunc func(err error, n int)
+
+// failureReporter is implemented by facilities that write records after
+// the write call returns, and report failures to the logger that opened
+// them.
+type failureReporter interface {
+	setFailed(fn failedFunc)
+}
+
+// NewFailoverFacility creates a facility that writes to primary facility
+// until it fails to write failures records in a row, and to secondary
+// facility from then on. While on secondary facility, a record is
+// written to primary facility once every probe interval, and if that
+// succeeds, primary facility takes over again. Records that primary
+// facility fails to write, or that it does not log at their priority,
+// are written to secondary facility. Each switch is reported with
+// a record of the logger written to the facility being switched to,
+// shortly after the write that caused it, or on Flush.
+// Line headers follow the settings of primary facility.
+func NewFailoverFacility(primary, secondary Facility, failures int, probe time.Duration) (Facility, error) {
+	if primary == nil || secondary == nil {
+		return nil, errNoFacility
+	}
+	if failures < 1 {
+		failures = 1
+	}
+	res := &fFailover{primary: primary, secondary: secondary, threshold: failures, probe: probe}
+	res.noticed = sync.NewCond(&res.noticeMu)
+	return res, nil
+}
+
+func (this *fFailover) OpenLogs(level Priority) (map[Priority]*log.Logger, error) {
+	for i, f := range []Facility{this.primary, this.secondary} {
+		ls, err := f.OpenLogs(level)
+		if err != nil {
+			return nil, err
+		}
+		this.logs[i] = ls
+	}
+	res := make(map[Priority]*log.Logger, prioritiesCount)
+	for pri := PriorityEmergency; pri <= PriorityTrace; pri++ {
+		l := this.logs[0][pri]
+		if l == nil || l == dscrd {
+			l = this.logs[1][pri]
+		}
+		if l == nil || l == dscrd {
+			res[pri] = drain.Logger()
+			continue
+		}
+		res[pri] = log.New(&failoverWriter{this, pri}, l.Prefix(), l.Flags())
+	}
+	return res, nil
+}
+
+type failoverWriter struct {
+	facility *fFailover
+	pri      Priority
+}
+
+func (this *failoverWriter) Write(p []byte) (int, error) {
+	return this.facility.write(this.pri, p)
+}
+
+// writer returns the writer of facility i for priority pri, if the
+// facility logs records of that priority.
+func (this *fFailover) writer(i int, pri Priority) (io.Writer, bool) {
+	if l := this.logs[i][pri]; l != nil && l != dscrd {
+		return l.Writer(), true
+	}
+	return nil, false
+}
+
+func (this *fFailover) write(pri Priority, p []byte) (int, error) {
+	n, written, notice := this.writePrimary(pri, p)
+	if notice != nil {
+		this.post(notice)
+	}
+	if written {
+		return n, nil
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	w, ok := this.writer(1, pri)
+	if !ok {
+		return 0, errFailoverPriority
+	}
+	return w.Write(p)
+}
+
+// post queues notice to be sent by another goroutine. Notices are logged
+// through the loggers whose writes they are reported from, which are
+// locked until the writes return.
+func (this *fFailover) post(notice func()) {
+	this.noticeMu.Lock()
+	defer this.noticeMu.Unlock()
+	this.notices = append(this.notices, notice)
+	if !this.sending {
+		this.sending = true
+		go this.send()
+	}
+}
+
+// send sends queued notices in order.
+func (this *fFailover) send() {
+	this.noticeMu.Lock()
+	defer this.noticeMu.Unlock()
+	for len(this.notices) > 0 {
+		notice := this.notices[0]
+		this.notices = this.notices[1:]
+		this.noticeMu.Unlock()
+		notice()
+		this.noticeMu.Lock()
+	}
+	this.sending = false
+	this.noticed.Broadcast()
+}
+
+// writePrimary writes p to primary facility if it is active or due to be
+// probed, and reports whether that succeeded. It also returns the function
+// that reports the switch of facilities, if there was one, to be posted
+// once the lock is released.
+func (this *fFailover) writePrimary(pri Priority, p []byte) (int, bool, func()) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	w, ok := this.writer(0, pri)
+	switch {
+	case !ok:
+		// Not logged by primary facility
+	case this.active == 0:
+		n, err := w.Write(p)
+		if err == nil {
+			this.failures = 0
+			return n, true, nil
+		}
+		if this.failures++; this.failures >= this.threshold {
+			return 0, false, this.switchTo(1, err)
+		}
+	case time.Since(this.probed) >= this.probe:
+		this.probed = time.Now()
+		if n, err := w.Write(p); err == nil {
+			return n, true, this.switchTo(0, nil)
+		}
+	}
+	return 0, false, nil
+}
+
+var errFailoverPriority = errors.New("priority not logged by secondary facility")
+
+// switchTo makes facility i active, and returns the function that reports
+// the switch.
+func (this *fFailover) switchTo(i int, err error) func() {
+	this.active, this.failures, this.probed = i, 0, time.Now()
+	var pri Priority
+	var message string
+	var v []interface{}
+	if i == 1 {
+		pri, message, v = PriorityError, "Switched logging to secondary facility", []interface{}{"failures", this.threshold}
+	} else {
+		pri, message = PriorityNotice, "Switched logging back to primary facility"
+	}
+	if fn := this.notify; fn != nil {
+		return func() { fn(pri, message, v, err) }
+	}
+	var es []error
+	if err != nil {
+		es = []error{err}
+	}
+	s := SimpleFormatter(message, v, es)
+	l := this.logs[i][pri]
+	return func() {
+		if l != nil {
+			l.Output(0, s)
+		}
+	}
+}
+
+func (this *fFailover) setNotify(fn notifyFunc) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.notify = fn
+}
+
+func (this *fFailover) setFailed(fn failedFunc) {
+	for _, f := range []Facility{this.primary, this.secondary} {
+		if r, ok := f.(failureReporter); ok {
+			r.setFailed(fn)
+		}
+	}
+}
+
+func (this *fFailover) Reopen() error {
+	err := this.primary.Reopen()
+	if err2 := this.secondary.Reopen(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// Flush waits for queued switch notices to be sent, and flushes both
+// facilities.
+func (this *fFailover) Flush() error {
+	this.noticeMu.Lock()
+	for this.sending {
+		this.noticed.Wait()
+	}
+	this.noticeMu.Unlock()
+	var res error
+	for _, f := range []Facility{this.primary, this.secondary} {
+		if fl, ok := f.(Flusher); ok {
+			if err := fl.Flush(); err != nil && res == nil {
+				res = err
+			}
+		}
+	}
+	return res
+}
+
+func (this *fFailover) Close() error {
+	return closeFacilities([]Facility{this.primary, this.secondary})
+}
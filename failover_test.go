@@ -0,0 +1,111 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tFlakyFacility fails writes while broken.
+type tFlakyFacility struct {
+	tFacility
+	broken bool
+}
+
+func (this *tFlakyFacility) OpenLogs(level Priority) (map[Priority]*log.Logger, error) {
+	res := make(map[Priority]*log.Logger, prioritiesCount)
+	for pri := PriorityEmergency; pri <= PriorityTrace; pri++ {
+		res[pri] = log.New(this, pri.Tag(), 0)
+	}
+	return res, nil
+}
+
+func (this *tFlakyFacility) Write(p []byte) (int, error) {
+	if this.broken {
+		return 0, errors.New("read-only file system")
+	}
+	return this.buf.Write(p)
+}
+
+func TestFailover(tst *testing.T) {
+	primary := &tFlakyFacility{}
+	secondary := &tFacility{}
+	f, _ := NewFailoverFacility(primary, secondary, 2, 0)
+	var reported []uint64
+	l, _ := New(f, PriorityInfo, CompactJsonFormatter, nil, WithWriteErrorHandler(func(err error, failures uint64) {
+		reported = append(reported, failures)
+	}, time.Hour))
+	l.Info().Prints("a")
+	primary.broken = true
+	l.Info().Prints("b")
+	l.Info().Prints("c")
+	l.Flush()
+	l.Info().Prints("d")
+	primary.broken = false
+	l.Info().Prints("e")
+	l.Flush()
+	l.Info().Prints("f")
+	exp := []string{"INFO a {}", "INFO e {}", "NOTICE Switched logging back to primary facility {}", "INFO f {}"}
+	if res := primary.lines(); strings.Join(res, "|") != strings.Join(exp, "|") {
+		tst.Errorf("fail: expected %q, but had %q", exp, res)
+	}
+	exp = []string{
+		"INFO b {}",
+		"INFO c {}",
+		`ERROR Switched logging to secondary facility {"errors":["read-only file system"],"failures":2}`,
+		"INFO d {}",
+	}
+	if res := secondary.lines(); strings.Join(res, "|") != strings.Join(exp, "|") {
+		tst.Errorf("fail: expected %q, but had %q", exp, res)
+	}
+	if len(reported) != 0 {
+		tst.Errorf("fail: expected no lost records, but had %v", reported)
+	}
+}
+
+func TestFailoverError(tst *testing.T) {
+	primary := &tFlakyFacility{broken: true}
+	secondary := &tFacility{}
+	f, _ := NewFailoverFacility(primary, secondary, 1, time.Hour)
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil, WithWriteErrorHandler(nil, 0))
+	done := make(chan struct{})
+	go func() {
+		l.On(errors.New("boom")).Prints("a")
+		l.Flush()
+		l.Error().Prints("b")
+		l.Flush()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		tst.Fatalf("fail: failover on error record hung")
+	}
+	exp := []string{
+		"ERROR a - error=boom",
+		"ERROR Switched logging to secondary facility failures=1 - error=read-only file system",
+		"ERROR b",
+	}
+	if res := secondary.lines(); strings.Join(res, "|") != strings.Join(exp, "|") {
+		tst.Errorf("fail: expected %q, but had %q", exp, res)
+	}
+}
+
+func TestWriteErrorHandler(tst *testing.T) {
+	var reported []uint64
+	l, _ := New(tFailingFacility{}, PriorityInfo, SimpleFormatter, nil, WithWriteErrorHandler(func(err error, failures uint64) {
+		reported = append(reported, failures)
+	}, 20*time.Millisecond))
+	for i := 0; i < 3; i++ {
+		l.Info().Prints("lost")
+	}
+	time.Sleep(30 * time.Millisecond)
+	l.Info().Prints("lost")
+	if len(reported) != 2 || reported[0] != 1 || reported[1] != 3 {
+		tst.Errorf("fail: unexpected reports %v", reported)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Fields is a list of alternating keys and values logged as a group under
+// the key it is the value of. Text formats render grouped keys with dotted
+// names, e.g. "user.id=1", JSON renders groups as nested objects.
+//
+// Fields found in place of a key are spliced into the enclosing list.
+type Fields []interface{}
+
+// Group returns key/value pairs kv grouped under name. It is passed in
+// place of a key/value pair, e.g. Prints("Login", Group("user", "id", 1)).
+func Group(name string, kv ...interface{}) Fields {
+	return Fields{name, Fields(kv)}
+}
+
+// expandGroups splices Fields found in place of keys into the enclosing
+// list, at any depth of grouping. It copies v only when there are some.
+func expandGroups(v []interface{}) []interface{} {
+	var res []interface{}
+	for i, val := range v {
+		fs, ok := val.(Fields)
+		if !ok {
+			if res != nil {
+				res = append(res, val)
+			}
+			continue
+		}
+		if res == nil {
+			res = append(make([]interface{}, 0, len(v)+2), v[:i]...)
+		}
+		if len(res)&1 == 0 {
+			res = append(res, expandGroups(fs)...)
+		} else {
+			res = append(res, Fields(expandGroups(fs)))
+		}
+	}
+	if res == nil {
+		return v
+	}
+	return res
+}
+
+// groupValues nests v in groups, outermost first.
+func groupValues(v []interface{}, groups []string) []interface{} {
+	if len(v) == 0 {
+		return v
+	}
+	for i := len(groups) - 1; i >= 0; i-- {
+		v = []interface{}{groups[i], Fields(v)}
+	}
+	return v
+}
+
+// WithGroup returns a logger that logs all the key/value pairs of its
+// records grouped under name. Fields added by the logger itself, such as
+// caller and stack, are not grouped.
+func (this *sLogger) WithGroup(name string) Logger {
+	return this.withGroup(name)
+}
+
+// withGroup groups the logs along with those of the buffered logger and
+// the trace log kept only by the flight recorder.
+func (this *sLogger) withGroup(name string) *sLogger {
+	res := *this
+	res.logs = make(map[Priority]Log, len(this.logs))
+	for p, l := range this.logs {
+		if sl, ok := l.(*sLog); ok {
+			l = groupLog(sl, name)
+		}
+		res.logs[p] = l
+	}
+	if this.flightTrace != nil {
+		res.flightTrace = groupLog(this.flightTrace, name)
+	}
+	if this.buffered != nil {
+		res.buffered = this.buffered.withGroup(name)
+		res.buffered.flightTrace = res.flightTrace
+	}
+	return &res
+}
+
+func groupLog(l *sLog, name string) *sLog {
+	if l == drain {
+		return l
+	}
+	res := *l
+	res.group = append(append(make([]string, 0, len(l.group)+1), l.group...), name)
+	return &res
+}
+
+type structField struct {
+	index     []int
+	name      string
+	omitEmpty bool
+}
+
+var structFieldCache sync.Map
+
+// structFields lists exported fields of struct type t named after their
+// log tags, e.g. `log:"name,omitempty"`. Fields tagged "-" are skipped.
+// Fields of embedded structs without tags are promoted. Fields promoted
+// through embedded pointers cannot be reached by index and are skipped.
+func structFields(t reflect.Type) []structField {
+	if fs, ok := structFieldCache.Load(t); ok {
+		return fs.([]structField)
+	}
+	res := embeddedFields(t, map[reflect.Type]bool{})
+	structFieldCache.Store(t, res)
+	return res
+}
+
+// embeddedFields lists fields of struct type t, skipping types in
+// visited, which are embedding it, to stop cycles.
+func embeddedFields(t reflect.Type, visited map[reflect.Type]bool) []structField {
+	visited[t] = true
+	defer delete(visited, t)
+	var res []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup("log")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if j := strings.Index(tag, ","); j >= 0 {
+			name, opts = tag[:j], tag[j+1:]
+		}
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && !hasTag && ft.Kind() == reflect.Struct {
+			if f.Type.Kind() == reflect.Ptr || visited[ft] {
+				continue
+			}
+			for _, ef := range embeddedFields(ft, visited) {
+				ef.index = append([]int{i}, ef.index...)
+				res = append(res, ef)
+			}
+			continue
+		}
+		if len(f.PkgPath) > 0 {
+			continue
+		}
+		if len(name) == 0 {
+			name = f.Name
+		}
+		res = append(res, structField{index: []int{i}, name: name, omitEmpty: opts == "omitempty"})
+	}
+	return res
+}
+
+// structValues flattens struct value into key/value pairs.
+func structValues(rv reflect.Value, depth int, redactors bool) Fields {
+	fs := structFields(rv.Type())
+	res := make(Fields, 0, 2*len(fs))
+	for _, f := range fs {
+		fv := rv.FieldByIndex(f.index)
+		if f.omitEmpty && fv.IsZero() {
+			continue
+		}
+		res = append(res, f.name, encodeDepth(fv.Interface(), depth+1, redactors))
+	}
+	return res
+}
+
+// mapValues flattens map value into key/value pairs sorted by key.
+func mapValues(rv reflect.Value, depth int, redactors bool) Fields {
+	keys := make([]string, 0, rv.Len())
+	vals := make(map[string]interface{}, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		k := asString(iter.Key().Interface())
+		keys = append(keys, k)
+		vals[k] = encodeDepth(iter.Value().Interface(), depth+1, redactors)
+	}
+	sort.Strings(keys)
+	res := make(Fields, 0, 2*len(keys))
+	for _, k := range keys {
+		res = append(res, k, vals[k])
+	}
+	return res
+}
@@ -0,0 +1,75 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"testing"
+)
+
+type tBase struct {
+	Kind string `log:"kind"`
+}
+
+type tUser struct {
+	tBase
+	ID       int    `log:"id"`
+	Name     string `log:"name,omitempty"`
+	Password string `log:"-"`
+	Address  struct {
+		City string
+	} `log:"addr"`
+	secret string
+}
+
+func TestStructFlattening(tst *testing.T) {
+	u := tUser{tBase: tBase{"admin"}, ID: 1, Password: "pwd", secret: "s"}
+	u.Address.City = "Oslo"
+	for _, t := range []struct {
+		fmtr Formatter
+		res  string
+	}{
+		{SimpleFormatter, "msg user.kind=admin user.id=1 user.addr.City=Oslo"},
+		{CompactJsonFormatter, `msg {"user":{"addr":{"City":"Oslo"},"id":1,"kind":"admin"}}`},
+	} {
+		if res := t.fmtr("msg", []interface{}{"user", &u}, nil); res != t.res {
+			tst.Errorf("fail: expected %q, but had %q", t.res, res)
+		}
+	}
+}
+
+type tNode struct {
+	*tNode
+	Name string
+}
+
+func TestStructEmbeddedPointer(tst *testing.T) {
+	n := &tNode{tNode: &tNode{Name: "parent"}, Name: "child"}
+	if res := SimpleFormatter("msg", []interface{}{"node", n}, nil); res != "msg node.Name=child" {
+		tst.Errorf("fail: expected \"msg node.Name=child\", but had %q", res)
+	}
+}
+
+func TestGroups(tst *testing.T) {
+	f := &tFacility{}
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil)
+	l.Info().Prints("a", Group("req", "id", 1, Group("peer", "ip", "::1")), "k", "v")
+	db := l.WithGroup("db").WithGroup("pool")
+	db.Info().Prints("b", "size", 2)
+	db.On(errEllipsis).Info().Prints("c")
+	l.Info().Prints("d", "size", 3)
+	exp := []string{
+		"INFO a req.id=1 req.peer.ip=::1 k=v",
+		"INFO b db.pool.size=2",
+		"INFO c - ...",
+		"INFO d size=3",
+	}
+	res := f.lines()
+	if len(res) != len(exp) {
+		tst.Fatalf("fail: expected %q, but had %q", exp, res)
+	}
+	for i := range exp {
+		if res[i] != exp[i] {
+			tst.Errorf("fail: expected %q, but had %q", exp[i], res[i])
+		}
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+//go:build !darwin && !linux && !netbsd && !openbsd
+// +build !darwin,!linux,!netbsd,!openbsd
+
+package slog
+
+import (
+	"os"
+)
+
+const oDsync = os.O_SYNC
+
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,21 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+//go:build darwin || linux || netbsd || openbsd
+// +build darwin linux netbsd openbsd
+
+package slog
+
+import (
+	"os"
+	"syscall"
+)
+
+const oDsync = syscall.O_DSYNC
+
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
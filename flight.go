@@ -0,0 +1,144 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Default number of records kept by a flight recorder.
+const DefaultFlightRecorderSize = 4096
+
+// FlightRecorder keeps the latest lines written by the loggers that use
+// it in memory, including records of all priorities up to any trace
+// verbosity that the loggers do not write to their facilities. Records
+// are formatted, but no I/O is done until the recorder is dumped, which
+// happens on Fatals, on Recover and on SIGQUIT and SIGUSR1 signals where
+// supported. After dumping on SIGQUIT, the recorder stops handling
+// signals and raises SIGQUIT again for other handlers or the runtime to
+// handle. SIGUSR1 is captured until the recorder is stopped, so it no
+// longer terminates the process.
+//
+// The recorder is a lock-free ring: concurrent records never wait for
+// each other, but a dump taken while records are being added may include
+// a few records newer than the others in place of the oldest ones.
+type FlightRecorder struct {
+	slots   []atomic.Value
+	next    uint64
+	path    string
+	mux     sync.Mutex
+	signals chan os.Signal
+}
+
+// NewFlightRecorder creates a recorder of the latest size lines that
+// dumps them to the file at path, or to standard error if path is empty.
+// Dumps are appended to the file.
+func NewFlightRecorder(size int, path string) *FlightRecorder {
+	if size < 1 {
+		size = DefaultFlightRecorderSize
+	}
+	res := &FlightRecorder{slots: make([]atomic.Value, size), path: path}
+	res.notify()
+	return res
+}
+
+// WithFlightRecorder makes the logger keep its records in flight recorder
+// r, along with the records of priorities more verbose than its level.
+func WithFlightRecorder(r *FlightRecorder) Option {
+	return func(l *sLogger) {
+		l.recorder = r
+	}
+}
+
+// flightOnly is the writer of loggers of priorities that are only kept
+// by flight recorders.
+type flightOnly struct{}
+
+func (flightOnly) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (this *FlightRecorder) add(s string) {
+	i := atomic.AddUint64(&this.next, 1) - 1
+	this.slots[i%uint64(len(this.slots))].Store(s)
+}
+
+// Lines returns the recorded lines, oldest first.
+func (this *FlightRecorder) Lines() []string {
+	n := atomic.LoadUint64(&this.next)
+	size := uint64(len(this.slots))
+	start := uint64(0)
+	if n > size {
+		start = n - size
+	}
+	res := make([]string, 0, n-start)
+	for i := start; i < n; i++ {
+		if s, ok := this.slots[i%size].Load().(string); ok {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// Dump writes the recorded lines out between markers naming the reason.
+func (this *FlightRecorder) Dump(reason string) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	var w io.Writer = os.Stderr
+	if len(this.path) > 0 {
+		f, err := os.OpenFile(this.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	lines := this.Lines()
+	buf := make([]byte, 0, 64*(len(lines)+2))
+	buf = append(buf, SimpleFormatter("Flight recorder dump begin", []interface{}{"reason", reason, "records", len(lines), "time", time.Now()}, nil)...)
+	buf = append(buf, '\n')
+	for _, s := range lines {
+		buf = append(buf, s...)
+		if len(s) == 0 || s[len(s)-1] != '\n' {
+			buf = append(buf, '\n')
+		}
+	}
+	buf = append(buf, SimpleFormatter("Flight recorder dump end", nil, nil)...)
+	buf = append(buf, '\n')
+	_, err := w.Write(buf)
+	return err
+}
+
+// crashed dumps the recorder, if there is one, reporting failures to
+// standard error.
+func (this *FlightRecorder) crashed(reason string) {
+	if this == nil {
+		return
+	}
+	if err := this.Dump(reason); err != nil {
+		fmt.Fprintf(os.Stderr, "slog: flight recorder dump failed: %v\n", err)
+	}
+}
+
+// Stop stops dumping the recorder on signals.
+func (this *FlightRecorder) Stop() {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.stop()
+}
+
+// flightLogger returns the logger for records of priority pri that are
+// only kept by the flight recorder, with the header flags of l.
+func flightLogger(pri Priority, l *log.Logger) *log.Logger {
+	flags := log.LstdFlags | log.Lmicroseconds
+	if l != nil && l != dscrd {
+		flags = l.Flags()
+	}
+	return log.New(flightOnly{}, pri.Tag(), flags)
+}
@@ -0,0 +1,13 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package slog
+
+// Signals are not supported.
+func (this *FlightRecorder) notify() {
+}
+
+func (this *FlightRecorder) stop() {
+}
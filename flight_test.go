@@ -0,0 +1,49 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFlightRecorder(tst *testing.T) {
+	path := filepath.Join(tst.TempDir(), "flight.log")
+	r := NewFlightRecorder(3, path)
+	defer r.Stop()
+	f := &tFacility{}
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil, WithFlightRecorder(r), WithCaller(CallerOff))
+	l.Info().Prints("started")
+	l.WithGroup("req").Trace(3).Prints("deep", "i", 1)
+	l.Debug().Printf("raw %d", 2)
+	l.On(errors.New("boom")).Debug().Prints("failed")
+	if res := f.lines(); strings.Join(res, "|") != "INFO started" {
+		tst.Errorf("fail: expected only info record, but had %q", res)
+	}
+	exp := []string{"TRACE deep req.i=1", "TRACE raw 2", "TRACE failed - error=boom"}
+	if res := r.Lines(); strings.Join(res, "|") != strings.Join(exp, "|") {
+		tst.Errorf("fail: expected %q, but had %q", exp, res)
+	}
+
+	func() {
+		defer func() {
+			recover()
+		}()
+		defer l.Recover("id", 5)
+		panic("bad state")
+	}()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		tst.Fatalf("fail: unable to read dump: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	if len(lines) < 5 || !strings.HasPrefix(lines[0], "Flight recorder dump begin reason=panic records=3 ") ||
+		lines[1] != "TRACE raw 2" || lines[2] != "TRACE failed - error=boom" ||
+		!strings.HasPrefix(lines[3], "ERROR Recovered panic id=5 panic=bad state ") ||
+		lines[len(lines)-1] != "Flight recorder dump end" {
+		tst.Errorf("fail: unexpected dump %q", lines)
+	}
+}
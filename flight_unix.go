@@ -0,0 +1,37 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package slog
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notify makes the recorder dump on SIGQUIT and SIGUSR1.
+func (this *FlightRecorder) notify() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGQUIT, syscall.SIGUSR1)
+	this.signals = c
+	go func() {
+		for sig := range c {
+			this.crashed(sig.String())
+			if sig == syscall.SIGQUIT {
+				// Leave the signal to other handlers, or to the runtime
+				this.Stop()
+				syscall.Kill(syscall.Getpid(), syscall.SIGQUIT)
+			}
+		}
+	}()
+}
+
+func (this *FlightRecorder) stop() {
+	if this.signals != nil {
+		signal.Stop(this.signals)
+		close(this.signals)
+		this.signals = nil
+	}
+}
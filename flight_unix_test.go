@@ -0,0 +1,38 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package slog
+
+import (
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFlightRecorderSIGQUIT(tst *testing.T) {
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, syscall.SIGQUIT)
+	defer signal.Stop(c)
+	path := filepath.Join(tst.TempDir(), "flight.log")
+	r := NewFlightRecorder(0, path)
+	defer r.Stop()
+	syscall.Kill(syscall.Getpid(), syscall.SIGQUIT)
+	// Raised once by the test and once more by the recorder
+	for i := 0; i < 2; i++ {
+		select {
+		case <-c:
+		case <-time.After(5 * time.Second):
+			tst.Fatalf("fail: SIGQUIT not passed on to other handlers")
+		}
+	}
+	if b, err := ioutil.ReadFile(path); err != nil || !strings.Contains(string(b), "reason=quit") {
+		tst.Errorf("fail: expected dump on SIGQUIT, but had %q: %v", b, err)
+	}
+}
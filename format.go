@@ -5,45 +5,93 @@ package slog
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"time"
+	"reflect"
+	"runtime"
+	"strings"
 )
 
+// LogFielder is implemented by errors that contribute their own key/value
+// pairs to the records they are logged with.
+type LogFielder interface {
+	LogFields() []interface{}
+}
+
 type Formatter func(string, []interface{}, []error) string
 
 func SimpleFormatter(message string, v []interface{}, es []error) string {
 	buf := &bytes.Buffer{}
-	sep := ""
-	if len(message) > 0 {
-		buf.WriteString(message)
-		sep = " "
-	}
-	for i := 0; i < len(v); i++ {
-		buf.WriteString(sep)
-		if i&1 == 0 {
-			sep = "="
-		} else {
-			sep = " "
-		}
-		buf.WriteString(asString(v[i]))
-	}
+	buf.WriteString(message)
+	writeValues(buf, "", v)
 	if es != nil && len(es) > 0 {
 		if buf.Len() > 0 {
 			buf.WriteString(" - ")
 		}
-		ml := len(es) > 1
-		for _, e := range es {
-			if ml {
-				buf.WriteString("\n\t")
+		writeErrors(buf, es)
+	}
+	return buf.String()
+}
+
+// writeErrors writes errors, one per line if there are several.
+func writeErrors(buf *bytes.Buffer, es []error) {
+	es = flattenErrors(es)
+	ml := len(es) > 1
+	for _, e := range es {
+		if ml {
+			buf.WriteString("\n\t")
+		}
+		if e == errSuccess || e == errEllipsis {
+			buf.WriteString(e.Error())
+		} else {
+			writeError(buf, e)
+		}
+	}
+}
+
+// writeValues writes key=value pairs, expanding groups of fields into
+// pairs with dotted keys.
+func writeValues(buf *bytes.Buffer, prefix string, v []interface{}) {
+	for i := 0; i < len(v); i += 2 {
+		var val interface{}
+		if i+1 < len(v) {
+			val = encodeValue(v[i+1])
+			if fs, ok := val.(Fields); ok && len(fs) > 0 {
+				writeValues(buf, prefix+asString(v[i])+".", fs)
+				continue
 			}
-			if e == errSuccess || e == errEllipsis {
-				buf.WriteString(e.Error())
+		}
+		if buf.Len() > 0 {
+			buf.WriteString(" ")
+		}
+		buf.WriteString(prefix)
+		buf.WriteString(asString(v[i]))
+		if i+1 < len(v) {
+			buf.WriteString("=")
+			buf.WriteString(textValue(val))
+		}
+	}
+}
+
+// writeError writes error message followed by Go types of the errors in
+// its unwrap chain and by its stack trace, if it has one.
+func writeError(buf *bytes.Buffer, e error) {
+	fmt.Fprintf(buf, "error=%v", e)
+	if chain := errorChain(e); len(chain) > 1 {
+		for i, c := range chain {
+			if i == 0 {
+				buf.WriteString(" [")
 			} else {
-				fmt.Fprintf(buf, "error=%v", e)
+				buf.WriteString(" > ")
 			}
+			buf.WriteString(errorType(c))
 		}
+		buf.WriteString("]")
+	}
+	for _, f := range stackFrames(errorStack(e)) {
+		buf.WriteString("\n\t\t")
+		buf.WriteString(f)
 	}
-	return buf.String()
 }
 
 func CompactJsonFormatter(message string, v []interface{}, e []error) string {
@@ -55,21 +103,15 @@ func PrettyJsonFormatter(message string, v []interface{}, e []error) string {
 }
 
 func jsonFormatter(message string, v []interface{}, e []error, pretty bool) string {
-	m := make(map[string]interface{})
-	for i := 0; i < len(v); i += 2 {
-		k, ok := v[i].(string)
-		if ok && i < (len(v)-1) {
-			m[k] = v[i+1]
-		}
-	}
+	m := jsonValues(v)
 	if e != nil && len(e) > 0 {
 		switch {
 		case len(e) == 1 && (e[0] == nil || e[0] == errSuccess):
 			m["success"] = true
 		default:
-			es := make([]string, 0, len(e))
-			for _, v := range e {
-				es = append(es, v.Error())
+			es := make([]interface{}, 0, len(e))
+			for _, v := range flattenErrors(e) {
+				es = append(es, jsonError(v))
 			}
 			m["errors"] = es
 		}
@@ -77,7 +119,7 @@ func jsonFormatter(message string, v []interface{}, e []error, pretty bool) stri
 	var b []byte
 	var err error
 	if pretty {
-		b, err = json.MarshalIndent(v, "", "    ")
+		b, err = json.MarshalIndent(m, "", "    ")
 	} else {
 		b, err = json.Marshal(m)
 	}
@@ -96,19 +138,183 @@ func jsonFormatter(message string, v []interface{}, e []error, pretty bool) stri
 }
 
 func asString(value interface{}) string {
-	if value == nil {
-		return "<nil>"
-	}
-	switch v := value.(type) {
-	case string:
-		return v
-	case time.Time:
-		return v.Format(time.RFC3339)
-	case error:
-		return v.Error()
-	case fmt.Stringer:
-		return v.String()
-	default:
-		return fmt.Sprintf("%+v", value)
+	return textValue(encodeValue(value))
+}
+
+// jsonValues maps keys to values, rendering groups of fields as nested
+// objects. Values without string keys are ignored.
+func jsonValues(v []interface{}) map[string]interface{} {
+	m := make(map[string]interface{})
+	for i := 0; i < len(v); i += 2 {
+		k, ok := v[i].(string)
+		if ok && i < (len(v)-1) {
+			val := encodeValue(v[i+1])
+			if fs, ok := val.(Fields); ok {
+				m[k] = jsonValues(fs)
+			} else {
+				m[k] = val
+			}
+		}
+	}
+	return m
+}
+
+// jsonError represents an error as its message, unless there is more to it,
+// in which case it is represented as an object with Go type, unwrap chain,
+// joined errors and stack trace.
+func jsonError(e error) interface{} {
+	chain := errorChain(e)
+	joined := joinedErrors(e)
+	stack := stackFrames(errorStack(e))
+	if len(chain) < 2 && len(joined) == 0 && len(stack) == 0 {
+		return e.Error()
+	}
+	res := map[string]interface{}{
+		"error": e.Error(),
+		"type":  errorType(chain[0]),
+	}
+	if len(chain) > 1 {
+		links := make([]map[string]string, 0, len(chain)-1)
+		for _, c := range chain[1:] {
+			links = append(links, map[string]string{"error": c.Error(), "type": errorType(c)})
+		}
+		res["chain"] = links
+	}
+	if len(joined) > 0 {
+		es := make([]interface{}, 0, len(joined))
+		for _, j := range joined {
+			es = append(es, jsonError(j))
+		}
+		res["errors"] = es
+	}
+	if len(stack) > 0 {
+		res["stack"] = stack
+	}
+	return res
+}
+
+// errorChain returns err followed by the errors it wraps, up to and
+// including the first one that joins several errors. Log-once markers
+// are skipped.
+func errorChain(err error) []error {
+	res := make([]error, 0, 1)
+	for err != nil {
+		if _, ok := originalError(err).(*loggedError); !ok {
+			res = append(res, err)
+		}
+		if _, ok := err.(interface{ Unwrap() []error }); ok {
+			break
+		}
+		err = errors.Unwrap(err)
+	}
+	return res
+}
+
+// errorType returns Go type of the error, or of the error it stands in
+// for if it is redacted.
+func errorType(e error) string {
+	return fmt.Sprintf("%T", originalError(e))
+}
+
+// joinedErrors returns the errors joined at the end of err's unwrap chain.
+func joinedErrors(err error) []error {
+	chain := errorChain(err)
+	if len(chain) == 0 {
+		return nil
+	}
+	if j, ok := chain[len(chain)-1].(interface{ Unwrap() []error }); ok {
+		return j.Unwrap()
+	}
+	return nil
+}
+
+// flattenErrors expands errors joined with errors.Join.
+func flattenErrors(es []error) []error {
+	res := make([]error, 0, len(es))
+	for _, e := range es {
+		if e == nil {
+			continue
+		}
+		if chain := errorChain(e); len(chain) == 1 {
+			if j, ok := chain[0].(interface{ Unwrap() []error }); ok {
+				res = append(res, flattenErrors(j.Unwrap())...)
+				continue
+			}
+		}
+		res = append(res, e)
+	}
+	return res
+}
+
+// walkErrors calls fn for err and every error it wraps or joins.
+func walkErrors(err error, fn func(error)) {
+	chain := errorChain(err)
+	for _, c := range chain {
+		fn(c)
+	}
+	for _, j := range joinedErrors(err) {
+		walkErrors(j, fn)
+	}
+}
+
+// errorFields collects key/value pairs contributed by the errors.
+func errorFields(es []error) []interface{} {
+	var res []interface{}
+	for _, e := range es {
+		walkErrors(e, func(err error) {
+			if f, ok := err.(LogFielder); ok {
+				res = append(res, f.LogFields()...)
+			}
+		})
+	}
+	return res
+}
+
+// errorStack returns the stack trace carried by the innermost error in
+// err's unwrap chain that has one. Errors carry stack traces by having
+// StackTrace method that returns a slice of program counters, such as
+// the ones created by github.com/pkg/errors.
+func errorStack(err error) []uintptr {
+	var res []uintptr
+	for _, c := range errorChain(err) {
+		if st := stackTrace(c); len(st) > 0 {
+			res = st
+		}
+	}
+	return res
+}
+
+func stackTrace(err error) []uintptr {
+	m := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+		return nil
+	}
+	if t := m.Type().Out(0); t.Kind() != reflect.Slice || t.Elem().Kind() != reflect.Uintptr {
+		return nil
+	}
+	v := m.Call(nil)[0]
+	res := make([]uintptr, v.Len())
+	for i := range res {
+		res[i] = uintptr(v.Index(i).Uint())
+	}
+	return res
+}
+
+// stackFrames renders program counters as "function file:line" strings.
+func stackFrames(pcs []uintptr) []string {
+	if len(pcs) == 0 {
+		return nil
+	}
+	res := make([]string, 0, len(pcs))
+	frames := runtime.CallersFrames(pcs)
+	for {
+		f, more := frames.Next()
+		if len(f.Function) > 0 || len(f.File) > 0 {
+			res = append(res, fmt.Sprintf("%s %s:%d", strings.TrimSpace(f.Function), f.File, f.Line))
+		}
+		if !more {
+			break
+		}
 	}
+	return res
 }
@@ -4,6 +4,9 @@ package slog
 
 import (
 	"errors"
+	"fmt"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 )
@@ -76,7 +79,7 @@ func TestCompactJsonFormatter(tst *testing.T) {
 		{"msg", []interface{}{"v", -1}, nil, "msg {\"v\":-1}"},
 		{"msg", []interface{}{"v", 0.1}, nil, "msg {\"v\":0.1}"},
 		{"msg", []interface{}{"v", t1}, nil, "msg {\"v\":\"" + t1s + "\"}"},
-		{"msg", []interface{}{"v", 10 * time.Second}, nil, "msg {\"v\":10000000000}"},
+		{"msg", []interface{}{"v", 10 * time.Second}, nil, "msg {\"v\":\"10s\"}"},
 	} {
 		testFormatter(CompactJsonFormatter, &t, tst)
 	}
@@ -92,3 +95,57 @@ func testFormatter(fmtr Formatter, tc *tcFormatter, tst *testing.T) {
 		tst.Logf("pass: \"%s\"", res)
 	}
 }
+
+type tFieldsError struct {
+	id int
+}
+
+func (this *tFieldsError) Error() string {
+	return "not found"
+}
+
+func (this *tFieldsError) LogFields() []interface{} {
+	return []interface{}{"id", this.id}
+}
+
+type tStackError struct {
+	stack []uintptr
+}
+
+func (this *tStackError) Error() string {
+	return "failed"
+}
+
+func (this *tStackError) StackTrace() []uintptr {
+	return this.stack
+}
+
+func TestErrorRendering(tst *testing.T) {
+	e1 := errors.New("e1")
+	e2 := fmt.Errorf("e2: %w", e1)
+	for _, t := range []tcFormatter{
+		{"msg", nil, []error{e2}, "msg - error=e2: e1 [*fmt.wrapError > *errors.errorString]"},
+		{"msg", nil, []error{errors.Join(e1, e2)}, "msg - \n\terror=e1\n\terror=e2: e1 [*fmt.wrapError > *errors.errorString]"},
+	} {
+		testFormatter(SimpleFormatter, &t, tst)
+	}
+	for _, t := range []tcFormatter{
+		{"", nil, []error{e2}, `{"errors":[{"chain":[{"error":"e1","type":"*errors.errorString"}],"error":"e2: e1","type":"*fmt.wrapError"}]}`},
+		{"", nil, []error{errors.Join(e1, e1)}, `{"errors":["e1","e1"]}`},
+		{"", nil, []error{fmt.Errorf("e3: %w", errors.Join(e1, e1))}, `{"errors":[{"chain":[{"error":"e1\ne1","type":"*errors.joinError"}],"error":"e3: e1\ne1","errors":["e1","e1"],"type":"*fmt.wrapError"}]}`},
+	} {
+		testFormatter(CompactJsonFormatter, &t, tst)
+	}
+	pcs := make([]uintptr, 1)
+	runtime.Callers(1, pcs)
+	res := SimpleFormatter("", nil, []error{fmt.Errorf("e: %w", &tStackError{stack: pcs})})
+	if !strings.Contains(res, "\n\t\tgithub.com/baobabus/slog.TestErrorRendering ") {
+		tst.Errorf("fail: no stack trace in %q", res)
+	}
+	f := &tFacility{}
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil)
+	l.On(fmt.Errorf("lookup: %w", &tFieldsError{id: 7})).Prints("get")
+	if res := f.lines(); len(res) != 1 || !strings.HasPrefix(res[0], "ERROR get id=7 - error=lookup: not found") {
+		tst.Errorf("fail: unexpected output %q", res)
+	}
+}
@@ -0,0 +1,9 @@
+module github.com/baobabus/slog
+
+go 1.20
+
+require (
+	golang.org/x/sys v0.10.0
+	golang.org/x/term v0.10.0
+	gopkg.in/yaml.v2 v2.4.0
+)
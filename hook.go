@@ -0,0 +1,144 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+)
+
+// Record is a structured record on its way from Log to the facility.
+type Record struct {
+	Time     time.Time
+	Priority Priority
+	Message  string
+	// Key/value pairs, with values encoded as formatters see them, see
+	// LogMarshaler. They must not be modified in place; use Add or assign
+	// a new slice.
+	Values []interface{}
+	Errors []error
+}
+
+// Add appends key/value pairs to the record.
+func (this *Record) Add(kv ...interface{}) {
+	this.Values = appendValues(this.Values, kv...)
+}
+
+// Clone returns a copy of the record that does not share values or
+// errors with it, e.g. for forwarding the record elsewhere.
+func (this *Record) Clone() *Record {
+	res := *this
+	res.Values = append([]interface{}(nil), this.Values...)
+	res.Errors = append([]error(nil), this.Errors...)
+	return &res
+}
+
+// Hook processes structured records after they have passed priority and
+// trace filter checks and have had their values resolved and redacted,
+// just before they are formatted. Hooks may add fields, rewrite
+// records, drop them by returning ErrDrop, or forward copies elsewhere.
+//
+// Errors returned by hooks and their panics are recorded in "hook_error"
+// fields of the record, which then continues down the pipeline.
+// Unstructured output, such as that of Printf, does not pass through hooks.
+type Hook interface {
+	Fire(r *Record) error
+}
+
+// HookFunc adapts a function to Hook.
+type HookFunc func(r *Record) error
+
+func (this HookFunc) Fire(r *Record) error {
+	return this(r)
+}
+
+// ErrDrop is returned by hooks to drop the record. Remaining hooks are
+// not run.
+var ErrDrop = errors.New("drop record")
+
+type hookEntry struct {
+	hook Hook
+	pris []Priority
+}
+
+// WithHook adds hook h to the logger for records of the specified
+// priorities, or of all priorities if none are specified. Hooks run in
+// the order they are added. Priorities match custom priorities as well as
+// their severities.
+func WithHook(h Hook, pris ...Priority) Option {
+	return func(l *sLogger) {
+		l.hooks = append(l.hooks, hookEntry{hook: h, pris: pris})
+	}
+}
+
+// hooksFor selects hooks for records of priority pri.
+func (this *sLogger) hooksFor(pri Priority) []Hook {
+	var res []Hook
+	for _, e := range this.hooks {
+		if len(e.pris) == 0 {
+			res = append(res, e.hook)
+			continue
+		}
+		for _, p := range e.pris {
+			if p == pri || p == pri.Bound() {
+				res = append(res, e.hook)
+				break
+			}
+		}
+	}
+	return res
+}
+
+// runHooks runs hooks on the record and reports whether it is to be
+// emitted.
+func runHooks(hooks []Hook, r *Record) bool {
+	for _, h := range hooks {
+		if err := fireHook(h, r); err == ErrDrop {
+			return false
+		} else if err != nil {
+			r.Add("hook_error", fmt.Sprintf("%T: %v", h, err))
+		}
+	}
+	return true
+}
+
+func fireHook(h Hook, r *Record) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return h.Fire(r)
+}
+
+// HostnameHook adds "host" field with the name of the host.
+func HostnameHook() Hook {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return HookFunc(func(r *Record) error {
+		r.Add("host", host)
+		return nil
+	})
+}
+
+// PidHook adds "pid" field with the process id.
+func PidHook() Hook {
+	pid := os.Getpid()
+	return HookFunc(func(r *Record) error {
+		r.Add("pid", pid)
+		return nil
+	})
+}
+
+// GoroutinesHook adds "goroutines" field with the number of goroutines.
+func GoroutinesHook() Hook {
+	return HookFunc(func(r *Record) error {
+		r.Add("goroutines", runtime.NumGoroutine())
+		return nil
+	})
+}
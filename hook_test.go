@@ -0,0 +1,63 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestHooks(tst *testing.T) {
+	var forwarded []*Record
+	f := &tFacility{}
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil,
+		WithHook(HookFunc(func(r *Record) error {
+			if r.Message == "noise" {
+				return ErrDrop
+			}
+			r.Add("seq", 1)
+			return nil
+		})),
+		WithHook(HookFunc(func(r *Record) error {
+			r.Message = strings.ToUpper(r.Message)
+			return nil
+		}), PriorityError),
+		WithHook(HookFunc(func(r *Record) error {
+			return errors.New("unavailable")
+		}), PriorityWarn),
+		WithHook(HookFunc(func(r *Record) error {
+			panic("boom")
+		}), PriorityNotice),
+		WithHook(HookFunc(func(r *Record) error {
+			forwarded = append(forwarded, r.Clone())
+			return nil
+		})),
+		WithHook(PidHook(), PriorityInfo),
+	)
+	v := []interface{}{"k", "v"}
+	l.Info().Prints("hello", v...)
+	l.Info().Prints("noise")
+	l.Error().Prints("failed")
+	l.Warning().Prints("warned")
+	l.Notice().Prints("noted")
+	l.Info().Printf("unstructured")
+	exp := []string{
+		fmt.Sprint("INFO hello k=v seq=1 pid=", os.Getpid()),
+		"ERROR FAILED seq=1",
+		"WARNING warned seq=1 hook_error=slog.HookFunc: unavailable",
+		"NOTICE noted seq=1 hook_error=slog.HookFunc: panic: boom",
+		"INFO unstructured",
+	}
+	if res := f.lines(); strings.Join(res, "|") != strings.Join(exp, "|") {
+		tst.Errorf("fail: expected %q, but had %q", exp, res)
+	}
+	if len(v) != 2 {
+		tst.Errorf("fail: caller's values modified")
+	}
+	if len(forwarded) != 4 || forwarded[1].Message != "FAILED" || forwarded[1].Priority != PriorityError {
+		tst.Errorf("fail: unexpected forwarded records %v", forwarded)
+	}
+}
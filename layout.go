@@ -0,0 +1,289 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Layout renders whole record lines according to a pattern, e.g.
+// "%time{15:04:05.000} %level{-7} [%caller] %msg %fields %errors".
+// Patterns consist of literal text and verbs, with optional arguments
+// in braces:
+//
+//	%time{layout}  record timestamp; layout is a time.Format layout or
+//	               one of layout names used in configuration; defaults
+//	               to the logger's time format
+//	%level{args}   priority; "long" (default) or "short" name, "upper"
+//	               (default) or "lower" case
+//	%caller{mode}  caller as with CallerMode names; defaults to "short"
+//	%msg           message
+//	%fields        key=value pairs
+//	%errors{indent} errors as SimpleFormatter renders them; "indent"
+//	               indents continuation lines of multi-line messages
+//	%%             percent sign
+//
+// All verbs but time take padding width as a number argument,
+// e.g. %level{-7}, with negative width padding on the right.
+// Literal text preceding a verb that renders empty is dropped, so that
+// "%msg %fields - %errors" does not leave dangling separators.
+type Layout struct {
+	pattern string
+	parts   []layoutPart
+	caller  bool
+	color   bool
+}
+
+type layoutVerb int
+
+const (
+	layoutText layoutVerb = iota
+	layoutTime
+	layoutLevel
+	layoutCaller
+	layoutMsg
+	layoutFields
+	layoutErrors
+)
+
+var layoutVerbs = map[string]layoutVerb{
+	"time":   layoutTime,
+	"level":  layoutLevel,
+	"caller": layoutCaller,
+	"msg":    layoutMsg,
+	"fields": layoutFields,
+	"errors": layoutErrors,
+}
+
+type layoutPart struct {
+	verb   layoutVerb
+	text   string
+	width  int
+	time   *timeFormat
+	short  bool
+	lower  bool
+	caller CallerMode
+	indent bool
+}
+
+var shortTags = map[Priority]string{
+	PriorityEmergency: "EMRG",
+	PriorityAlert:     "ALRT",
+	PriorityCritical:  "CRIT",
+	PriorityError:     "ERRO",
+	PriorityWarn:      "WARN",
+	PriorityNotice:    "NOTE",
+	PriorityInfo:      "INFO",
+	PriorityTrace:     "TRCE",
+}
+
+// ParseLayout compiles layout pattern.
+func ParseLayout(pattern string) (*Layout, error) {
+	res := &Layout{pattern: pattern}
+	text := &bytes.Buffer{}
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c != '%' {
+			text.WriteByte(c)
+			continue
+		}
+		if i+1 < len(pattern) && pattern[i+1] == '%' {
+			text.WriteByte('%')
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(pattern) && pattern[j] >= 'a' && pattern[j] <= 'z' {
+			j++
+		}
+		verb, ok := layoutVerbs[pattern[i+1:j]]
+		if !ok {
+			return nil, fmt.Errorf("unsupported layout verb at %d: %q", i, pattern[i:j])
+		}
+		args := ""
+		if j < len(pattern) && pattern[j] == '{' {
+			k := strings.IndexByte(pattern[j:], '}')
+			if k < 0 {
+				return nil, fmt.Errorf("unterminated layout verb arguments at %d", j)
+			}
+			args = pattern[j+1 : j+k]
+			j += k + 1
+		}
+		if text.Len() > 0 {
+			res.parts = append(res.parts, layoutPart{verb: layoutText, text: text.String()})
+			text.Reset()
+		}
+		part, err := newLayoutPart(verb, args)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", pattern[i:j], err)
+		}
+		res.parts = append(res.parts, part)
+		res.caller = res.caller || verb == layoutCaller
+		i = j - 1
+	}
+	if text.Len() > 0 {
+		res.parts = append(res.parts, layoutPart{verb: layoutText, text: text.String()})
+	}
+	return res, nil
+}
+
+func newLayoutPart(verb layoutVerb, args string) (layoutPart, error) {
+	res := layoutPart{verb: verb, caller: CallerShort}
+	if verb == layoutTime {
+		if len(args) > 0 {
+			tf, err := parseTimeFormat(args, "")
+			if err != nil {
+				return res, err
+			}
+			res.time = tf
+		}
+		return res, nil
+	}
+	if len(args) == 0 {
+		return res, nil
+	}
+	for _, arg := range strings.Split(args, ",") {
+		arg = strings.TrimSpace(arg)
+		if w, err := strconv.Atoi(arg); err == nil {
+			res.width = w
+			continue
+		}
+		switch {
+		case verb == layoutLevel && arg == "short":
+			res.short = true
+		case verb == layoutLevel && arg == "long":
+			res.short = false
+		case verb == layoutLevel && arg == "lower":
+			res.lower = true
+		case verb == layoutLevel && arg == "upper":
+			res.lower = false
+		case verb == layoutErrors && arg == "indent":
+			res.indent = true
+		case verb == layoutCaller:
+			mode, err := ParseCallerMode(arg)
+			if err != nil {
+				return res, err
+			}
+			res.caller = mode
+		default:
+			return res, fmt.Errorf("unsupported argument: %q", arg)
+		}
+	}
+	return res, nil
+}
+
+func (this *Layout) String() string {
+	return this.pattern
+}
+
+// WithLayout makes the logger render record lines with layout l instead
+// of the formatter and the headers of facility loggers. Facilities that
+// stamp records themselves, such as syslog, still do.
+func WithLayout(l *Layout) Option {
+	return func(lg *sLogger) {
+		lg.layout = l
+	}
+}
+
+func (this *Layout) render(r *Record, frame runtime.Frame, tf *timeFormat) string {
+	buf := &bytes.Buffer{}
+	// Start of the last literal text, -1 if the last part was a verb
+	text := -1
+	for _, p := range this.parts {
+		if p.verb == layoutText {
+			text = buf.Len()
+			buf.WriteString(p.text)
+			continue
+		}
+		start := buf.Len()
+		p.render(buf, r, frame, tf)
+		if n := buf.Len() - start; n == 0 {
+			if text >= 0 {
+				buf.Truncate(text)
+			}
+		} else if n < abs(p.width) {
+			pad := strings.Repeat(" ", abs(p.width)-n)
+			if p.width > 0 {
+				s := buf.String()[start:]
+				buf.Truncate(start)
+				buf.WriteString(pad + s)
+			} else {
+				buf.WriteString(pad)
+			}
+		}
+		if c := p.color(r); this.color && len(c) > 0 && buf.Len() > start {
+			s := buf.String()[start:]
+			buf.Truncate(start)
+			buf.WriteString(c + s + colorReset)
+		}
+		text = -1
+	}
+	return buf.String()
+}
+
+func (this *layoutPart) render(buf *bytes.Buffer, r *Record, frame runtime.Frame, tf *timeFormat) {
+	switch this.verb {
+	case layoutTime:
+		if this.time != nil {
+			tf = &timeFormat{layout: this.time.layout, loc: tf.loc}
+		}
+		if len(tf.layout) == 0 {
+			tf = &timeFormat{layout: TimeDefault, loc: tf.loc}
+		}
+		buf.Write(tf.append(nil, r.Time))
+	case layoutLevel:
+		s := strings.TrimSpace(r.Priority.Tag())
+		if this.short {
+			if t, ok := shortTags[r.Priority]; ok {
+				s = t
+			} else if len(s) > 4 {
+				s = s[:4]
+			}
+		}
+		if this.lower {
+			s = strings.ToLower(s)
+		}
+		buf.WriteString(s)
+	case layoutCaller:
+		if frame.PC != 0 {
+			buf.WriteString(formatCaller(frame, this.caller))
+		}
+	case layoutMsg:
+		buf.WriteString(r.Message)
+	case layoutFields:
+		b := &bytes.Buffer{}
+		writeValues(b, "", r.Values)
+		buf.Write(b.Bytes())
+	case layoutErrors:
+		if len(r.Errors) > 0 {
+			b := &bytes.Buffer{}
+			writeErrors(b, r.Errors)
+			if this.indent {
+				buf.WriteString(indentLines(b.String()))
+			} else {
+				buf.Write(b.Bytes())
+			}
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// layoutOutput writes the record rendered with the layout.
+// The argument calldepth has the same meaning as for log.Logger.Output.
+func (this *sLog) layoutOutput(calldepth int, r *Record) error {
+	var frame runtime.Frame
+	if this.layout.caller {
+		frame, _ = this.callerFrame(calldepth)
+	}
+	return this.write(this.layout.render(r, frame, this.time))
+}
@@ -0,0 +1,55 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestLayout(tst *testing.T) {
+	t1 := time.Date(2016, time.February, 21, 21, 3, 37, 120000000, time.UTC)
+	clock := WithClock(ClockFunc(func() time.Time { return t1 }))
+	for _, t := range []struct {
+		pattern string
+		log     func(l Logger)
+		res     string
+	}{
+		{"%time{15:04:05.000} %level{-7}|%msg %fields - %errors", func(l Logger) { l.Info().Prints("hi", "k", 1) }, "21:03:37.120 INFO   |hi k=1"},
+		{"%time{rfc3339} %level{short,lower} %msg - %errors", func(l Logger) { l.On(errors.New("boom")).Warning().Prints("hi") }, "2016-02-21T21:03:37Z warn hi - error=boom"},
+		{"%level{8}: %msg%%", func(l Logger) { l.Error().Printf("%d", 5) }, "   ERROR: 5%"},
+		{"%level %msg", func(l Logger) { l.Notice().Println("plain") }, "NOTICE plain"},
+		{"errors: %errors|%level %msg", func(l Logger) { l.Info().Prints("hi") }, "|INFO hi"},
+	} {
+		f := &tFacility{}
+		lt, err := ParseLayout(t.pattern)
+		if err != nil {
+			tst.Errorf("fail: %v", err)
+			continue
+		}
+		l, _ := New(f, PriorityInfo, SimpleFormatter, nil, clock, WithTimeFormat("", time.UTC), WithLayout(lt))
+		t.log(l)
+		if res := f.lines(); len(res) != 1 || res[0] != t.res {
+			tst.Errorf("fail: expected %q, but had %q", t.res, res)
+		}
+	}
+	f := &tFacility{}
+	lt, _ := ParseLayout("[%caller] %msg")
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil, WithLayout(lt))
+	_, _, line, _ := runtime.Caller(0)
+	l.Info().Prints("hi")
+	if res, exp := f.lines(), fmt.Sprint("[layout_test.go:", line+1, "] hi"); len(res) != 1 || res[0] != exp {
+		tst.Errorf("fail: expected %q, but had %q", exp, res)
+	}
+	for _, p := range []string{"%foo", "%level{wide}", "%msg{", "%caller{up}"} {
+		if _, err := ParseLayout(p); err == nil {
+			tst.Errorf("fail: expected error for %q", p)
+		}
+	}
+	if err := (Config{Format: "%level %bad"}).Validate(); err == nil {
+		tst.Errorf("fail: expected invalid layout format to fail validation")
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"fmt"
+)
+
+// LogValuer is implemented by values that are expensive to compute or
+// that should be logged differently from how they are. LogValue is only
+// called for records that pass priority and trace filter checks.
+// It may return Fields to log a group of fields in place of the value.
+//
+// Values of func() interface{} type are resolved the same way.
+type LogValuer interface {
+	LogValue() interface{}
+}
+
+// Limit on LogValue returning more LogValuers.
+const maxResolveDepth = 8
+
+// resolveValues evaluates lazy values, copying v only when there are some.
+func resolveValues(v []interface{}) []interface{} {
+	res, _ := resolveList(v)
+	return res
+}
+
+func resolveList(v []interface{}) ([]interface{}, bool) {
+	var res []interface{}
+	for i, val := range v {
+		nv, lazy := resolveValue(val, 0)
+		if !lazy {
+			continue
+		}
+		if res == nil {
+			res = append(make([]interface{}, 0, len(v)), v...)
+		}
+		res[i] = nv
+	}
+	if res == nil {
+		return v, false
+	}
+	return res, true
+}
+
+func resolveValue(val interface{}, depth int) (interface{}, bool) {
+	var res interface{}
+	switch l := val.(type) {
+	case func() interface{}:
+		res = safeResolve(l, "func")
+	case LogValuer:
+		res = safeResolve(l.LogValue, "LogValue method")
+	case Fields:
+		fs, lazy := resolveList(l)
+		return Fields(fs), lazy
+	default:
+		return val, false
+	}
+	if depth < maxResolveDepth {
+		if nv, lazy := resolveValue(res, depth+1); lazy {
+			res = nv
+		}
+	}
+	return res, true
+}
+
+// safeResolve calls fn, reporting its panic in place of the value
+// the way fmt reports panics in String methods.
+func safeResolve(fn func() interface{}, what string) (res interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			res = fmt.Sprintf("%%!v(PANIC=%s: %v)", what, r)
+		}
+	}()
+	return fn()
+}
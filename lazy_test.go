@@ -0,0 +1,50 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"strings"
+	"testing"
+)
+
+type tSummary struct {
+	calls *int
+}
+
+func (this tSummary) LogValue() interface{} {
+	*this.calls++
+	return Fields{"count", 2, "size", func() interface{} { return 10 }}
+}
+
+func TestLazyValues(tst *testing.T) {
+	calls := 0
+	dump := func() interface{} {
+		calls++
+		return "dump"
+	}
+	f := &tFacility{}
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil)
+	l.Trace(1).Prints("skipped", "dump", dump, "summary", tSummary{&calls})
+	l, _ = New(f, PriorityTrace, SimpleFormatter, []string{"nosuchfile.go"})
+	l.Trace(1).Prints("filtered", "dump", dump, "summary", tSummary{&calls})
+	if calls != 0 {
+		tst.Errorf("fail: lazy values resolved for discarded records")
+	}
+	l, _ = New(f, PriorityInfo, SimpleFormatter, nil)
+	l.Info().Prints("logged", "dump", dump, "summary", tSummary{&calls})
+	l.Info().Prints("panic", "v", func() interface{} { panic("boom") })
+	exp := []string{
+		"INFO logged dump=dump summary.count=2 summary.size=10",
+		"INFO panic v=%!v(PANIC=func: boom)",
+	}
+	if res := f.lines(); strings.Join(res, "\n") != strings.Join(exp, "\n") {
+		tst.Errorf("fail: expected %q, but had %q", exp, res)
+	}
+	if calls != 2 {
+		tst.Errorf("fail: expected 2 resolutions, but had %d", calls)
+	}
+	res := CompactJsonFormatter("", resolveValues([]interface{}{"summary", tSummary{&calls}}), nil)
+	if res != `{"summary":{"count":2,"size":10}}` {
+		tst.Errorf("fail: unexpected JSON %s", res)
+	}
+}
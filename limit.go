@@ -0,0 +1,295 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type limitKind int
+
+const (
+	limitOnce limitKind = iota + 1
+	limitEvery
+	limitDuration
+	limitRate
+)
+
+// limit describes how often a call site may log.
+type limit struct {
+	kind  limitKind
+	n     int
+	d     time.Duration
+	rate  float64
+	burst int
+}
+
+// limitKey identifies limiter state. Site is empty for explicit keys.
+type limitKey struct {
+	site  string
+	key   interface{}
+	limit limit
+}
+
+type limiter struct {
+	mux     sync.Mutex
+	count   int
+	last    time.Time
+	used    time.Time
+	tokens  float64
+	skipped int
+}
+
+// Limit on the number of limiter states a logger keeps. Once it is
+// exceeded, states of EveryDuration and Limit that are idle for long
+// enough to be back to their initial state are dropped, and then the
+// least recently used states, down to half of the limit.
+const maxLimiters = 4096
+
+// limiters holds limiter states of a logger by call site or key.
+type limiters struct {
+	state sync.Map
+	count int64
+	mux   sync.Mutex
+}
+
+// get returns limiter state for the key, creating it if there is none.
+func (this *limiters) get(key limitKey, now time.Time) *limiter {
+	if v, ok := this.state.Load(key); ok {
+		return v.(*limiter)
+	}
+	v, loaded := this.state.LoadOrStore(key, &limiter{})
+	if !loaded && atomic.AddInt64(&this.count, 1) > maxLimiters {
+		this.evict(now)
+	}
+	return v.(*limiter)
+}
+
+// evict drops limiter states down to half of maxLimiters. Records being
+// limited with dropped states at the time are counted in them still,
+// but the counts are lost.
+func (this *limiters) evict(now time.Time) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if atomic.LoadInt64(&this.count) <= maxLimiters {
+		return
+	}
+	type entry struct {
+		key  limitKey
+		used time.Time
+	}
+	var kept []entry
+	this.state.Range(func(k, v interface{}) bool {
+		key, lr := k.(limitKey), v.(*limiter)
+		lr.mux.Lock()
+		idle, used := lr.idle(key.limit, now), lr.used
+		lr.mux.Unlock()
+		if idle {
+			this.drop(key)
+		} else {
+			kept = append(kept, entry{key: key, used: used})
+		}
+		return true
+	})
+	n := int(atomic.LoadInt64(&this.count)) - maxLimiters/2
+	if n <= 0 {
+		return
+	}
+	if n > len(kept) {
+		n = len(kept)
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].used.Before(kept[j].used) })
+	for _, e := range kept[:n] {
+		this.drop(e.key)
+	}
+}
+
+func (this *limiters) drop(key limitKey) {
+	this.state.Delete(key)
+	atomic.AddInt64(&this.count, -1)
+}
+
+// idle reports whether the state is no different from the initial one.
+func (this *limiter) idle(l limit, now time.Time) bool {
+	if this.skipped > 0 {
+		return false
+	}
+	switch l.kind {
+	case limitDuration:
+		return now.Sub(this.last) >= l.d
+	case limitRate:
+		return this.tokens+now.Sub(this.last).Seconds()*l.rate >= float64(l.burst)
+	}
+	return false
+}
+
+// Once returns a Log that logs only the first record from the call site,
+// or for the key set with Key.
+func (this *sLog) Once() Log {
+	return this.limited(limit{kind: limitOnce})
+}
+
+// Every returns a Log that logs the first and then every n-th record from
+// the call site, or for the key set with Key.
+func (this *sLog) Every(n int) Log {
+	if n < 1 {
+		n = 1
+	}
+	return this.limited(limit{kind: limitEvery, n: n})
+}
+
+// EveryDuration returns a Log that logs at most one record per interval d
+// from the call site, or for the key set with Key.
+func (this *sLog) EveryDuration(d time.Duration) Log {
+	return this.limited(limit{kind: limitDuration, d: d})
+}
+
+// Limit returns a Log that logs records from the call site, or for the key
+// set with Key, at the rate of up to rate records per second with bursts
+// of up to burst records.
+func (this *sLog) Limit(rate float64, burst int) Log {
+	if burst < 1 {
+		burst = 1
+	}
+	return this.limited(limit{kind: limitRate, rate: rate, burst: burst})
+}
+
+// Key returns a Log that keeps the state of Once, Every, EveryDuration
+// and Limit for the key rather than for the call site, e.g. for
+// limiting records per customer. Records with keys that are not
+// comparable are limited per call site.
+func (this *sLog) Key(key interface{}) Log {
+	if this == drain {
+		return drain
+	}
+	res := *this
+	if isComparable(key) {
+		res.limitKey = key
+	}
+	return &res
+}
+
+// isComparable reports whether key can be compared, and so used as
+// a map key.
+func isComparable(key interface{}) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	_ = key == key
+	return true
+}
+
+func (this *sLog) limited(l limit) Log {
+	if this == drain {
+		return drain
+	}
+	res := *this
+	res.limit = &l
+	return &res
+}
+
+// allow reports whether the record is within the limit, and how many
+// records were skipped since the previous one that was.
+// The argument calldepth identifies the call site as for callerFrame.
+func (this *sLog) allow(calldepth int) (bool, int) {
+	key := limitKey{key: this.limitKey, limit: *this.limit}
+	if key.key == nil {
+		if f, ok := callerFrame(calldepth + 1); ok {
+			key.site = f.File + ":" + strconv.Itoa(f.Line)
+		}
+	}
+	now := this.clock.Now()
+	lr := this.limiters.get(key, now)
+	lr.mux.Lock()
+	defer lr.mux.Unlock()
+	lr.used = now
+	allowed := false
+	switch l := this.limit; l.kind {
+	case limitOnce:
+		allowed = lr.count == 0
+	case limitEvery:
+		allowed = lr.count%l.n == 0
+	case limitDuration:
+		allowed = lr.count == 0 || now.Sub(lr.last) >= l.d
+	case limitRate:
+		if lr.count == 0 {
+			lr.tokens = float64(l.burst)
+		} else if lr.tokens += now.Sub(lr.last).Seconds() * l.rate; lr.tokens > float64(l.burst) {
+			lr.tokens = float64(l.burst)
+		}
+		if allowed = lr.tokens >= 1; allowed {
+			lr.tokens--
+		}
+	}
+	if l := this.limit; l.kind != limitDuration || allowed || lr.count == 0 {
+		lr.last = now
+	}
+	lr.count++
+	if !allowed {
+		lr.skipped++
+		return false, 0
+	}
+	skipped := lr.skipped
+	lr.skipped = 0
+	return true, skipped
+}
+
+// WithSampling makes the logger log records of the specified priorities
+// with the probabilities given, e.g. only 1% of trace records with
+// map[Priority]float64{PriorityTrace: 0.01}. Rates for severities apply
+// to custom priorities without rates of their own.
+func WithSampling(rates map[Priority]float64) Option {
+	return func(l *sLogger) {
+		l.sampling = rates
+	}
+}
+
+// sampleRate returns sampling rate for priority pri, or 1 if there is
+// no sampling.
+func (this *sLogger) sampleRate(pri Priority) float64 {
+	if r, ok := this.sampling[pri]; ok {
+		return r
+	}
+	if r, ok := this.sampling[pri.Bound()]; ok {
+		return r
+	}
+	return 1
+}
+
+func sampled(rate float64) bool {
+	return rate >= 1 || rand.Float64() < rate
+}
+
+// ParseSampling parses sampling rates given as comma-separated list of
+// priority names and rates, e.g. "trace=0.01,info=0.5".
+func ParseSampling(s string) (map[Priority]float64, error) {
+	res := make(map[Priority]float64)
+	for _, item := range splitList(s) {
+		i := strings.Index(item, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid sampling rate: %q", item)
+		}
+		pri, err := ParsePriority(item[:i])
+		if err != nil {
+			return nil, err
+		}
+		if customPriority(pri) == nil {
+			pri = pri.Bound()
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(item[i+1:]), 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid sampling rate: %q", item)
+		}
+		res[pri] = rate
+	}
+	return res, nil
+}
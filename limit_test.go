@@ -0,0 +1,96 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimits(tst *testing.T) {
+	now := time.Date(2016, time.February, 21, 21, 3, 37, 0, time.UTC)
+	f := &tFacility{}
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil, WithClock(ClockFunc(func() time.Time { return now })))
+	for i := 0; i < 3; i++ {
+		l.Info().Once().Prints("once", "i", i)
+	}
+	for i := 0; i < 5; i++ {
+		l.Info().Every(2).Prints("every", "i", i)
+	}
+	for i := 0; i < 4; i++ {
+		l.Info().EveryDuration(time.Minute).Prints("duration", "i", i)
+		now = now.Add(40 * time.Second)
+	}
+	for i := 0; i < 5; i++ {
+		l.Info().Limit(1, 2).Prints("limit", "i", i)
+		if i == 2 {
+			now = now.Add(time.Second)
+		}
+	}
+	for _, k := range []string{"a", "b", "a"} {
+		l.Info().Key(k).Once().Prints("keyed", "k", k)
+	}
+	exp := []string{
+		"INFO once i=0",
+		"INFO every i=0",
+		"INFO every i=2 skipped=1",
+		"INFO every i=4 skipped=1",
+		"INFO duration i=0",
+		"INFO duration i=2 skipped=1",
+		"INFO limit i=0",
+		"INFO limit i=1",
+		"INFO limit i=3 skipped=1",
+		"INFO keyed k=a",
+		"INFO keyed k=b",
+	}
+	if res := f.lines(); strings.Join(res, "|") != strings.Join(exp, "|") {
+		tst.Errorf("fail: expected %q, but had %q", exp, res)
+	}
+}
+
+func TestLimiterKeys(tst *testing.T) {
+	now := time.Date(2016, time.February, 21, 21, 3, 37, 0, time.UTC)
+	f := &tFacility{}
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil, WithClock(ClockFunc(func() time.Time { return now })))
+	for i := 0; i < 2; i++ {
+		l.Info().Key([]string{"a"}).Once().Prints("unhashable")
+	}
+	if res := f.lines(); strings.Join(res, "|") != "INFO unhashable" {
+		tst.Errorf("fail: expected unhashable key to be limited per call site, but had %q", res)
+	}
+	for i := 0; i < 2*maxLimiters; i++ {
+		now = now.Add(time.Millisecond)
+		l.Info().Key(i).EveryDuration(time.Second).Prints("idle")
+		l.Info().Key("hot").Once().Prints("hot")
+	}
+	if n := atomic.LoadInt64(&l.(*sLogger).limiters.count); n > maxLimiters {
+		tst.Errorf("fail: expected at most %d limiter states, but had %d", maxLimiters, n)
+	}
+	if res := f.lines(); len(res) != 2*maxLimiters+1 {
+		tst.Errorf("fail: expected %d records, but had %d", 2*maxLimiters+1, len(res))
+	}
+}
+
+func TestSampling(tst *testing.T) {
+	rates, err := ParseSampling("trace2=0, info=0.5")
+	if err != nil || len(rates) != 2 || rates[PriorityTrace] != 0 || rates[PriorityInfo] != 0.5 {
+		tst.Fatalf("fail: unexpected rates %v: %v", rates, err)
+	}
+	if _, err := ParseSampling("info=2"); err == nil {
+		tst.Errorf("fail: expected invalid rate to fail")
+	}
+	f := &tFacility{}
+	m := &Metrics{name: "sampling"}
+	l, _ := New(f, PriorityTrace, SimpleFormatter, nil, WithSampling(rates), WithMetrics(m), WithCaller(CallerOff))
+	for i := 0; i < 1000; i++ {
+		l.Trace(1).Prints("trace")
+		l.Info().Prints("info")
+		l.Error().Prints("error")
+	}
+	n := len(f.lines())
+	if n < 1000+400 || n > 1000+600 || m.Sampled() != uint64(3000-n) {
+		tst.Errorf("fail: unexpected number of records %d, sampled %d", n, m.Sampled())
+	}
+}
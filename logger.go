@@ -10,6 +10,7 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"time"
 )
 
 var (
@@ -20,32 +21,160 @@ var (
 )
 
 type sLogger struct {
-	facility  Facility
-	level     Priority
-	formatter Formatter
-	logs      map[Priority]Log
-}
-
-func New(facility Facility, level Priority, formatter Formatter, filter []string) (Logger, error) {
+	facility    Facility
+	level       Priority
+	formatter   Formatter
+	logs        map[Priority]Log
+	classifier  *ErrorClassifier
+	stack       Priority
+	callers     map[Priority]CallerMode
+	clock       Clock
+	time        *timeFormat
+	redaction   *Redaction
+	layout      *Layout
+	hooks       []hookEntry
+	metrics     *Metrics
+	writeErrors *writeErrorLimiter
+	dedup       *dedup
+	sampling    map[Priority]float64
+	limiters    *limiters
+	buffering   Priority
+	buffered    *sLogger
+	recorder    *FlightRecorder
+	flightTrace *sLog
+}
+
+// Option configures optional Logger behaviour.
+type Option func(*sLogger)
+
+func New(facility Facility, level Priority, formatter Formatter, filter []string, opts ...Option) (Logger, error) {
 	if facility == nil {
 		return nil, errNoFacility
 	}
 	if formatter == nil {
 		return nil, errNoFormatter
 	}
-	ls, err := facility.OpenLogs(level)
+	res := &sLogger{facility: facility, level: level, formatter: formatter, stack: noStack, callers: defaultCallers(), clock: SystemClock, metrics: DefaultMetrics, buffering: PriorityEmergency, limiters: &limiters{}}
+	for _, opt := range opts {
+		opt(res)
+	}
+	open := level
+	if res.buffering > level {
+		open = res.buffering
+	}
+	ls, err := facility.OpenLogs(open)
 	if err != nil {
 		return nil, err
 	}
+	if lf, ok := facility.(Layouter); ok && res.layout == nil && isSimpleFormatter(formatter) {
+		res.layout = lf.Layout()
+	}
+	if n, ok := facility.(notifier); ok {
+		n.setNotify(res.notice)
+	}
+	if r, ok := facility.(failureReporter); ok {
+		r.setFailed(res.failed)
+	}
+	if open > level {
+		b := *res
+		b.level = open
+		b.logs = b.buildLogs(ls, open, filter)
+		res.buffered = &b
+	}
+	res.logs = res.buildLogs(ls, level, filter)
+	if res.recorder != nil {
+		res.flightTrace = res.newLog(PriorityTrace, flightLogger(PriorityTrace, ls[PriorityEmergency]), filter)
+		if res.buffered != nil {
+			res.buffered.flightTrace = res.flightTrace
+		}
+	}
+	return res, err
+}
+
+// notice logs a record on behalf of the facility.
+func (this *sLogger) notice(pri Priority, message string, v []interface{}, err error) {
+	var errs []error
+	if err != nil {
+		errs = []error{err}
+	}
+	if l, ok := this.Log(pri).(*sLog); ok {
+		l.prints(1, message, v, errs)
+	}
+}
+
+// failed reports failure of a deferred write of n records, as writes of
+// the logger's records are.
+func (this *sLogger) failed(err error, n int) {
+	this.metrics.failed(n)
+	if we := this.writeErrors; we != nil {
+		we.failed(err, uint64(n))
+	} else {
+		defaultWriteErrors().failed(err, uint64(n))
+	}
+}
+
+// buildLogs builds logs of priorities up to level from facility logs ls.
+// Priorities beyond level are off, or only kept by the flight recorder.
+func (this *sLogger) buildLogs(ls map[Priority]*log.Logger, level Priority, filter []string) map[Priority]Log {
 	logs := make(map[Priority]Log, len(ls))
 	for p, l := range ls {
+		if p > level {
+			if this.recorder != nil {
+				l = flightLogger(p, ls[PriorityEmergency])
+			} else {
+				l = dscrd
+			}
+		}
 		if p < PriorityTrace {
-			logs[p] = &sLog{formatter: formatter, logger: l, scope: nil}
+			logs[p] = this.newLog(p, l, nil)
 		} else {
-			logs[p] = &sLog{formatter: formatter, logger: l, filter: filter, scope: nil}
+			logs[p] = this.newLog(p, l, filter)
+		}
+	}
+	for _, p := range CustomPriorities() {
+		if base := logs[p.Bound()].(*sLog); base.logger != dscrd {
+			l := log.New(base.logger.Writer(), p.Tag(), base.logger.Flags())
+			logs[p] = this.newLog(p, l, base.filter)
+		}
+	}
+	return logs
+}
+
+func (this *sLogger) newLog(pri Priority, l *log.Logger, filter []string) *sLog {
+	res := &sLog{
+		formatter:   this.formatter,
+		logger:      l,
+		filter:      filter,
+		scope:       nil,
+		stack:       pri.Bound() <= this.stack,
+		caller:      this.callers[pri.Bound()],
+		clock:       this.clock,
+		redaction:   this.redaction,
+		pri:         pri,
+		hooks:       this.hooksFor(pri),
+		metrics:     this.metrics,
+		writeErrors: this.writeErrors,
+		dedup:       this.dedup,
+		recorder:    this.recorder,
+		limiters:    this.limiters,
+		flush:       this.Flush,
+	}
+	if r := this.sampleRate(pri); r < 1 {
+		res.sampling, res.sample = true, r
+	}
+	if _, ok := l.Writer().(flightOnly); ok {
+		res.recordOnly = true
+		res.hooks, res.dedup, res.sampling = nil, nil, false
+	}
+	if l != dscrd {
+		res.out = log.New(l.Writer(), "", 0)
+		res.layout = this.layout
+		res.time = this.time
+		if res.time == nil {
+			res.time = flagsTimeFormat(l.Flags())
 		}
 	}
-	return &sLogger{facility: facility, level: level, formatter: formatter, logs: logs}, err
+	return res
 }
 
 func (this *sLogger) Level() Priority {
@@ -57,9 +186,19 @@ func (this *sLogger) Formatter() Formatter {
 }
 
 func (this *sLogger) Log(pri Priority) Log {
+	if l, ok := this.logs[pri]; ok {
+		return l
+	}
+	if pri > PriorityTrace && pri < priorityCustomBase {
+		return this.Trace(int(pri-PriorityTrace) + 1)
+	}
 	return this.logs[pri.Bound()]
 }
 
+func (this *sLogger) Debug() Log {
+	return this.Trace(1)
+}
+
 func (this *sLogger) Info() Log {
 	return this.logs[PriorityInfo]
 }
@@ -76,9 +215,23 @@ func (this *sLogger) Error() Log {
 	return this.logs[PriorityError]
 }
 
+func (this *sLogger) Critical() Log {
+	return this.logs[PriorityCritical]
+}
+
+func (this *sLogger) Alert() Log {
+	return this.logs[PriorityAlert]
+}
+
+func (this *sLogger) Emergency() Log {
+	return this.logs[PriorityEmergency]
+}
+
 func (this *sLogger) Trace(detail int) Log {
 	if PriorityTrace+Priority(detail-1) <= this.level {
 		return this.logs[PriorityTrace]
+	} else if this.flightTrace != nil {
+		return this.flightTrace
 	} else {
 		return drain
 	}
@@ -100,11 +253,36 @@ func (this *sLogger) With(err ...error) Selector {
 }
 
 type sLog struct {
-	formatter Formatter
-	logger    *log.Logger
-	filter    []string
-	scope     []error
-	soff      int
+	formatter   Formatter
+	logger      *log.Logger
+	filter      []string
+	scope       []error
+	soff        int
+	stack       bool
+	caller      CallerMode
+	clock       Clock
+	time        *timeFormat
+	out         *log.Logger
+	redaction   *Redaction
+	group       []string
+	template    bool
+	pri         Priority
+	layout      *Layout
+	hooks       []Hook
+	metrics     *Metrics
+	writeErrors *writeErrorLimiter
+	dedup       *dedup
+	sampling    bool
+	sample      float64
+	limit       *limit
+	limitKey    interface{}
+	limiters    *limiters
+	buffer      *Buffer
+	hold        bool
+	recorder    *FlightRecorder
+	recordOnly  bool
+	site        *runtime.Frame
+	flush       func() error
 }
 
 func (this *sLog) Printe(message string, v ...interface{}) {
@@ -117,6 +295,10 @@ func (this *sLog) Prints(message string, v ...interface{}) {
 
 func (this *sLog) Fatals(message string, v ...interface{}) {
 	this.prints(2, message, v, this.scope)
+	this.recorder.crashed("fatal")
+	if this.flush != nil {
+		this.flush()
+	}
 	os.Exit(1)
 }
 
@@ -128,20 +310,24 @@ func (this *sLog) ScopedLog(err ...error) Log {
 	if err == nil || len(err) == 0 || (len(err) == 1 && err[0] == nil) {
 		return drain
 	}
-	return &sLog{formatter: this.formatter, logger: this.logger, filter: this.filter, scope: err}
+	res := *this
+	res.scope = err
+	return &res
 }
 
 func (this *sLog) Offset(stackOffset int) Log {
-	return &sLog{formatter: this.formatter, logger: this.logger, filter: this.filter, scope: this.scope, soff: this.soff + stackOffset}
+	res := *this
+	res.soff += stackOffset
+	return &res
 }
 
 func (this *sLog) filteredLogger(calldepth int) *log.Logger {
 	if len(this.filter) == 0 {
 		return this.logger
 	}
-	if _, file, _, ok := runtime.Caller(calldepth + this.soff); ok {
+	if frame, ok := callerFrame(calldepth + this.soff); ok {
 		for _, f := range this.filter {
-			if strings.HasSuffix(file, f) {
+			if strings.HasSuffix(frame.File, f) {
 				return this.logger
 			}
 		}
@@ -149,31 +335,256 @@ func (this *sLog) filteredLogger(calldepth int) *log.Logger {
 	return dscrd
 }
 
-func (this *sLog) prints(calldepth int, message string, v []interface{}, err []error) error {
+// prints logs the record and reports whether it was written.
+func (this *sLog) prints(calldepth int, message string, v []interface{}, err []error) bool {
+	logger := this.filteredLogger(calldepth + 1)
+	if logger == dscrd {
+		if this.logger != dscrd {
+			this.metrics.countFiltered()
+		}
+		return false
+	}
+	if this.sampling && !sampled(this.sample) {
+		this.metrics.countSampled()
+		return false
+	}
+	var skipped int
+	if this.limit != nil {
+		var ok bool
+		if ok, skipped = this.allow(calldepth + this.soff); !ok {
+			this.metrics.countSampled()
+			return false
+		}
+	}
 	if err == nil {
 		err = this.scope
 	}
-	s := message
+	v = expandGroups(v)
+	var tmpl *msgTemplate
+	if this.template {
+		tmpl = parseTemplate(message)
+		v = resolveValues(v)
+		if missing, unused := tmpl.check(v); len(missing) > 0 || len(unused) > 0 {
+			v = appendTemplateIssues(v, missing, unused)
+		}
+	}
+	v = groupValues(v, this.group)
+	if skipped > 0 {
+		v = appendValues(v, "skipped", skipped)
+	}
+	if tmpl != nil {
+		v = appendValues(v, "template", message, "event_type", tmpl.eventType)
+	}
+	if this.caller != CallerOff {
+		v = appendValues(v, "caller", this.callerInfo(calldepth))
+	}
+	if fs := errorFields(err); len(fs) > 0 {
+		v = appendValues(v, fs...)
+	}
+	if this.stack {
+		v = appendValues(v, "stack", CaptureStack(calldepth+this.soff))
+	}
+	r := this.redactor()
+	v = encodeValues(resolveValues(v), r != nil)
+	if r != nil {
+		message, v = r.apply(message, v)
+		err = r.redactErrors(err)
+	}
+	if tmpl != nil {
+		// Rendered from redacted values, but may still reveal secrets
+		// returned by Redactors
+		message = tmpl.render(lookupValues(v, this.group))
+		if r != nil {
+			message, _ = r.apply(message, nil)
+		}
+	}
+	rec := &Record{Time: this.clock.Now(), Priority: this.pri, Message: message, Values: v, Errors: err}
+	if len(this.hooks) > 0 && !runHooks(this.hooks, rec) {
+		this.metrics.countDropped()
+		return false
+	}
+	if this.dedup != nil {
+		f, _ := this.callerFrame(calldepth + this.soff)
+		if !this.dedup.admit(this.at(f), logger, rec) {
+			this.metrics.countDropped()
+			return false
+		}
+	}
+	if this.buffer != nil && !this.buffer.admit(this.held(calldepth+this.soff), logger, rec, false) {
+		return false
+	}
+	return this.emit(logger, calldepth+this.soff+1, rec) == nil
+}
+
+// emit formats and writes the record.
+// The argument calldepth has the same meaning as for log.Logger.Output.
+func (this *sLog) emit(logger *log.Logger, calldepth int, rec *Record) error {
+	if this.layout != nil && this.out != nil {
+		return this.layoutOutput(calldepth+1, rec)
+	}
+	s := rec.Message
 	if this.formatter != nil {
-		s = this.formatter(message, v, err)
+		s = this.formatter(rec.Message, rec.Values, rec.Errors)
 	}
-	return this.filteredLogger(calldepth+1).Output(calldepth+this.soff+1, s)
+	return this.output(logger, calldepth+1, rec.Time, s)
 }
 
 func (this *sLog) Output(calldepth int, s string) error {
-	return this.filteredLogger(calldepth).Output(calldepth+this.soff+1, s)
+	return this.unstructured(this.filteredLogger(calldepth+1), calldepth+this.soff+1, this.withCaller(calldepth, s))
 }
 
 func (this *sLog) Printf(format string, v ...interface{}) {
-	this.filteredLogger(2).Output(2, fmt.Sprintf(format, v...))
+	this.unstructured(this.filteredLogger(2), 2+this.soff, this.withCaller(1, fmt.Sprintf(format, v...)))
 }
 
 func (this *sLog) Print(v ...interface{}) {
-	this.filteredLogger(2).Output(2, fmt.Sprint(v...))
+	this.unstructured(this.filteredLogger(2), 2+this.soff, this.withCaller(1, fmt.Sprint(v...)))
 }
 
 func (this *sLog) Println(v ...interface{}) {
-	this.filteredLogger(2).Output(2, fmt.Sprintln(v...))
+	this.unstructured(this.filteredLogger(2), 2+this.soff, this.withCaller(1, fmt.Sprintln(v...)))
+}
+
+// unstructured writes s as output does, with its content redacted,
+// passing it through the buffer first if there is one.
+func (this *sLog) unstructured(logger *log.Logger, calldepth int, s string) error {
+	if r := this.redactor(); r != nil && logger != dscrd {
+		s, _ = r.apply(s, nil)
+	}
+	if this.buffer == nil || logger == dscrd {
+		return this.output(logger, calldepth+1, time.Time{}, s)
+	}
+	rec := &Record{Time: this.clock.Now(), Priority: this.pri, Message: s}
+	if !this.buffer.admit(this.held(calldepth), logger, rec, true) {
+		return nil
+	}
+	return this.output(logger, calldepth+1, rec.Time, s)
+}
+
+// output writes s with the header facility logger would, but with
+// timestamp t, or taken from the clock if t is zero, rendered in the
+// configured format.
+// The argument calldepth has the same meaning as for log.Logger.Output.
+func (this *sLog) output(logger *log.Logger, calldepth int, t time.Time, s string) error {
+	if logger == dscrd {
+		if this.logger != dscrd {
+			this.metrics.countFiltered()
+		}
+		return nil
+	}
+	if this.out == nil {
+		return logger.Output(calldepth+1, s)
+	}
+	if t.IsZero() {
+		t = this.clock.Now()
+	}
+	if this.layout != nil {
+		return this.layoutOutput(calldepth+1, &Record{Time: t, Priority: this.pri, Message: s})
+	}
+	flags := logger.Flags()
+	buf := make([]byte, 0, 64+len(s))
+	if flags&log.Lmsgprefix == 0 {
+		buf = append(buf, logger.Prefix()...)
+	}
+	if flags&(log.Ldate|log.Ltime|log.Lmicroseconds) != 0 {
+		n := len(buf)
+		if buf = this.time.append(buf, t); len(buf) > n {
+			buf = append(buf, ' ')
+		}
+	}
+	if flags&(log.Lshortfile|log.Llongfile) != 0 && this.caller == CallerOff {
+		mode := CallerShort
+		if flags&log.Llongfile != 0 && flags&log.Lshortfile == 0 {
+			mode = CallerFull
+		}
+		if f, ok := this.callerFrame(calldepth); ok {
+			buf = append(append(buf, formatCaller(f, mode)...), ": "...)
+		}
+	}
+	if flags&log.Lmsgprefix != 0 {
+		buf = append(buf, logger.Prefix()...)
+	}
+	buf = append(buf, s...)
+	return this.write(string(buf))
+}
+
+// write writes a line to the facility, counting it in metrics, and keeps
+// it in the flight recorder.
+func (this *sLog) write(s string) error {
+	if this.recorder != nil {
+		this.recorder.add(s)
+		if this.recordOnly {
+			return nil
+		}
+	}
+	start := time.Now()
+	err := this.out.Output(0, s)
+	n := len(s)
+	if n == 0 || s[n-1] != '\n' {
+		n++
+	}
+	this.metrics.written(this.pri, n, time.Since(start), err)
+	if err != nil {
+		if we := this.writeErrors; we != nil {
+			we.failed(err, 1)
+		} else {
+			defaultWriteErrors().failed(err, 1)
+		}
+	}
+	return err
+}
+
+// callerFrame returns the frame of the call site as the package function
+// does, or the frame the log is bound to.
+func (this *sLog) callerFrame(skip int) (runtime.Frame, bool) {
+	if this.site != nil {
+		return *this.site, true
+	}
+	return callerFrame(skip + 1)
+}
+
+// at returns a copy of the log bound to frame f, for records written out
+// away from their call site.
+func (this *sLog) at(f runtime.Frame) *sLog {
+	res := *this
+	res.site = &f
+	return &res
+}
+
+// held returns the log for records that the buffer may hold, bound to
+// the call site skip levels up the stack from the caller of held.
+func (this *sLog) held(skip int) *sLog {
+	if !this.hold {
+		return this
+	}
+	f, _ := this.callerFrame(skip + 1)
+	return this.at(f)
+}
+
+// redactor returns redaction policy of the log.
+func (this *sLog) redactor() *Redaction {
+	if this.redaction != nil {
+		return this.redaction
+	}
+	return DefaultRedaction()
+}
+
+// callerInfo describes the caller skip levels up the stack from the caller
+// of callerInfo.
+func (this *sLog) callerInfo(skip int) string {
+	if f, ok := callerFrame(skip + this.soff + 1); ok {
+		return formatCaller(f, this.caller)
+	}
+	return "???"
+}
+
+// withCaller prefixes unstructured output with caller information,
+// the way log.Lshortfile does.
+func (this *sLog) withCaller(skip int, s string) string {
+	if this.caller == CallerOff {
+		return s
+	}
+	return this.callerInfo(skip+1) + ": " + s
 }
 
 var dscrd = log.New(ioutil.Discard, "", 0)
@@ -185,7 +596,11 @@ type sSelector struct {
 }
 
 func (this *sSelector) Log(pri Priority) Log {
-	return this.logs[pri.Bound()].ScopedLog(this.scope...)
+	return this.sLogger.Log(pri).ScopedLog(this.scope...)
+}
+
+func (this *sSelector) Debug() Log {
+	return this.Trace(1)
 }
 
 func (this *sSelector) Info() Log {
@@ -204,37 +619,87 @@ func (this *sSelector) Error() Log {
 	return this.logs[PriorityError].ScopedLog(this.scope...)
 }
 
+func (this *sSelector) Critical() Log {
+	return this.logs[PriorityCritical].ScopedLog(this.scope...)
+}
+
+func (this *sSelector) Alert() Log {
+	return this.logs[PriorityAlert].ScopedLog(this.scope...)
+}
+
+func (this *sSelector) Emergency() Log {
+	return this.logs[PriorityEmergency].ScopedLog(this.scope...)
+}
+
 func (this *sSelector) Trace(detail int) Log {
 	if PriorityTrace+Priority(detail-1) <= this.level {
 		return this.logs[PriorityTrace].ScopedLog(this.scope...)
+	} else if this.flightTrace != nil {
+		return this.flightTrace.ScopedLog(this.scope...)
 	} else {
 		return drain
 	}
 }
 
 func (this *sSelector) Prints(message string, v ...interface{}) {
-	this.scopedLog().prints(2, message, v, nil)
+	l, v := this.scopedLog(v)
+	l.prints(2, message, v, nil)
 }
 
 func (this *sSelector) Fatals(message string, v ...interface{}) {
-	this.scopedLog().prints(2, message, v, nil)
+	l, v := this.scopedLog(v)
+	l.prints(2, message, v, nil)
 	if !this.isSuccess() {
+		this.recorder.crashed("fatal")
+		this.Flush()
 		os.Exit(1)
 	}
 }
 
+func (this *sSelector) Return(message string, v ...interface{}) error {
+	l, v := this.scopedLog(v)
+	if !l.prints(2, message, v, nil) {
+		return combineErrors(this.scope)
+	}
+	return markLogged(this.scope)
+}
+
 func (this *sSelector) Logger() *log.Logger {
-	return this.scopedLog().Logger()
+	l, _ := this.scopedLog(nil)
+	return l.Logger()
 }
 
 func (this *sSelector) isSuccess() bool {
 	return this.scope == nil || len(this.scope) == 1 && (this.scope[0] == nil || this.scope[0] == errSuccess || this.scope[0] == errEllipsis)
 }
 
-func (this *sSelector) scopedLog() Log {
+// scopedLog selects the log for the record by classifying scope errors.
+// Name of the deciding classification rule is added to v.
+func (this *sSelector) scopedLog(v []interface{}) (Log, []interface{}) {
+	c := this.classifier
+	if c == nil {
+		c = DefaultErrorClassifier()
+	}
 	if this.isSuccess() {
-		return this.logs[PriorityNotice].ScopedLog(this.scope...)
-	} else {
-		return this.logs[PriorityError].ScopedLog(this.scope...)
+		return this.Log(c.successPriority()), v
+	}
+	pri, rule, ok := c.outcome(this.scope)
+	if !ok {
+		return drain, v
+	}
+	if len(rule) > 0 {
+		v = appendValues(v, "error_rule", rule)
+	}
+	return this.Log(pri), v
+}
+
+// appendValues appends key/value pairs to v without disturbing pairing of
+// the values already there or modifying v's backing array.
+func appendValues(v []interface{}, kv ...interface{}) []interface{} {
+	res := make([]interface{}, 0, len(v)+len(kv))
+	if len(v)&1 == 0 {
+		return append(append(res, v...), kv...)
 	}
+	res = append(append(res, v[:len(v)-1]...), kv...)
+	return append(res, v[len(v)-1])
 }
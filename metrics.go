@@ -0,0 +1,217 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics counts records and writes of the loggers that use it.
+// Registered metrics are served in Prometheus text format by
+// MetricsHandler, with metrics name as "facility" label, and can be
+// published with expvar using MetricsVars.
+type Metrics struct {
+	name        string
+	records     [prioritiesCount]uint64
+	bytes       uint64
+	writeErrors uint64
+	dropped     uint64
+	filtered    uint64
+	sampled     uint64
+	writeNanos  uint64
+}
+
+var (
+	metricsMu sync.Mutex
+	metrics   = make(map[string]*Metrics)
+)
+
+// DefaultMetrics is used by loggers that were not given metrics of their
+// own with WithMetrics option.
+var DefaultMetrics = NewMetrics("default")
+
+// NewMetrics returns metrics registered under name, creating them if
+// there are none.
+func NewMetrics(name string) *Metrics {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	if m, ok := metrics[name]; ok {
+		return m
+	}
+	m := &Metrics{name: name}
+	metrics[name] = m
+	return m
+}
+
+// WithMetrics makes the logger count its records with m instead of
+// DefaultMetrics.
+func WithMetrics(m *Metrics) Option {
+	return func(l *sLogger) {
+		l.metrics = m
+	}
+}
+
+func (this *Metrics) Name() string {
+	return this.name
+}
+
+// Records returns the number of records of priority pri, including those
+// that failed to be written.
+func (this *Metrics) Records(pri Priority) uint64 {
+	return atomic.LoadUint64(&this.records[pri.Bound()-PriorityEmergency])
+}
+
+// Bytes returns the number of bytes written.
+func (this *Metrics) Bytes() uint64 {
+	return atomic.LoadUint64(&this.bytes)
+}
+
+// WriteErrors returns the number of records that failed to be written.
+func (this *Metrics) WriteErrors() uint64 {
+	return atomic.LoadUint64(&this.writeErrors)
+}
+
+// Dropped returns the number of records dropped by hooks and suppressed
+// as duplicates.
+func (this *Metrics) Dropped() uint64 {
+	return atomic.LoadUint64(&this.dropped)
+}
+
+// Filtered returns the number of trace records rejected by trace filter.
+func (this *Metrics) Filtered() uint64 {
+	return atomic.LoadUint64(&this.filtered)
+}
+
+// Sampled returns the number of records skipped by sampling and rate
+// limits.
+func (this *Metrics) Sampled() uint64 {
+	return atomic.LoadUint64(&this.sampled)
+}
+
+// WriteTime returns the total time spent writing records.
+func (this *Metrics) WriteTime() time.Duration {
+	return time.Duration(atomic.LoadUint64(&this.writeNanos))
+}
+
+// written counts the outcome of writing a record.
+func (this *Metrics) written(pri Priority, n int, d time.Duration, err error) {
+	if this == nil {
+		return
+	}
+	atomic.AddUint64(&this.records[pri.Bound()-PriorityEmergency], 1)
+	atomic.AddUint64(&this.writeNanos, uint64(d))
+	if err != nil {
+		atomic.AddUint64(&this.writeErrors, 1)
+	} else {
+		atomic.AddUint64(&this.bytes, uint64(n))
+	}
+}
+
+// failed counts n records that failed to be written after their write
+// was deferred.
+func (this *Metrics) failed(n int) {
+	if this != nil {
+		atomic.AddUint64(&this.writeErrors, uint64(n))
+	}
+}
+
+func (this *Metrics) countDropped() {
+	if this != nil {
+		atomic.AddUint64(&this.dropped, 1)
+	}
+}
+
+func (this *Metrics) countFiltered() {
+	if this != nil {
+		atomic.AddUint64(&this.filtered, 1)
+	}
+}
+
+func (this *Metrics) countSampled() {
+	if this != nil {
+		atomic.AddUint64(&this.sampled, 1)
+	}
+}
+
+// snapshot returns metrics by their names, sorted by name.
+func snapshot() []*Metrics {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	res := make([]*Metrics, 0, len(metrics))
+	for _, m := range metrics {
+		res = append(res, m)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].name < res[j].name })
+	return res
+}
+
+// MetricsVars returns all registered metrics as a map by name, for
+// publishing with expvar, e.g.
+//
+//	expvar.Publish("slog", expvar.Func(slog.MetricsVars))
+func MetricsVars() interface{} {
+	res := make(map[string]interface{})
+	for _, m := range snapshot() {
+		records := make(map[string]uint64, prioritiesCount)
+		for pri := PriorityEmergency; pri <= PriorityTrace; pri++ {
+			records[pri.String()] = m.Records(pri)
+		}
+		res[m.name] = map[string]interface{}{
+			"records":       records,
+			"bytes":         m.Bytes(),
+			"write_errors":  m.WriteErrors(),
+			"dropped":       m.Dropped(),
+			"filtered":      m.Filtered(),
+			"sampled":       m.Sampled(),
+			"write_seconds": m.WriteTime().Seconds(),
+		}
+	}
+	return res
+}
+
+// MetricsHandler serves all metrics in Prometheus text exposition format.
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(prometheusText(snapshot()))
+	})
+}
+
+func prometheusText(ms []*Metrics) []byte {
+	buf := &bytes.Buffer{}
+	header := func(name, help, typ string) {
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+	header("slog_records_total", "Records by priority, including those that failed to be written.", "counter")
+	for _, m := range ms {
+		for pri := PriorityEmergency; pri <= PriorityTrace; pri++ {
+			fmt.Fprintf(buf, "slog_records_total{facility=%q,priority=%q} %d\n", m.name, pri.String(), m.Records(pri))
+		}
+	}
+	for _, c := range []struct {
+		name, help string
+		value      func(m *Metrics) uint64
+	}{
+		{"slog_bytes_total", "Bytes written.", (*Metrics).Bytes},
+		{"slog_write_errors_total", "Records that failed to be written.", (*Metrics).WriteErrors},
+		{"slog_dropped_total", "Records dropped by hooks and suppressed as duplicates.", (*Metrics).Dropped},
+		{"slog_filtered_total", "Trace records rejected by trace filter.", (*Metrics).Filtered},
+		{"slog_sampled_total", "Records skipped by sampling and rate limits.", (*Metrics).Sampled},
+	} {
+		header(c.name, c.help, "counter")
+		for _, m := range ms {
+			fmt.Fprintf(buf, "%s{facility=%q} %d\n", c.name, m.name, c.value(m))
+		}
+	}
+	header("slog_write_seconds_total", "Time spent writing records.", "counter")
+	for _, m := range ms {
+		fmt.Fprintf(buf, "slog_write_seconds_total{facility=%q} %g\n", m.name, m.WriteTime().Seconds())
+	}
+	return buf.Bytes()
+}
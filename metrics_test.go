@@ -0,0 +1,81 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// tFailingFacility fails all writes.
+type tFailingFacility struct{}
+
+func (this tFailingFacility) OpenLogs(level Priority) (map[Priority]*log.Logger, error) {
+	res := make(map[Priority]*log.Logger, prioritiesCount)
+	for pri := PriorityEmergency; pri <= PriorityTrace; pri++ {
+		res[pri] = log.New(this, pri.Tag(), 0)
+	}
+	return res, nil
+}
+
+func (this tFailingFacility) Reopen() error {
+	return nil
+}
+
+func (this tFailingFacility) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestMetrics(tst *testing.T) {
+	if NewMetrics("test") != NewMetrics("test") {
+		tst.Errorf("fail: expected metrics to be registered once")
+	}
+	m := &Metrics{name: "test"}
+	f := &tFacility{}
+	l, _ := New(f, PriorityTrace, SimpleFormatter, []string{"nothing.go"}, WithMetrics(m), WithCaller(CallerOff),
+		WithHook(HookFunc(func(r *Record) error {
+			if r.Message == "noise" {
+				return ErrDrop
+			}
+			return nil
+		})))
+	l.Error().Prints("a")
+	l.Error().Prints("b")
+	l.Info().Printf("c")
+	l.Info().Prints("noise")
+	l.Trace(1).Prints("filtered")
+	if m.Records(PriorityError) != 2 || m.Records(PriorityInfo) != 1 || m.Dropped() != 1 || m.Filtered() != 1 {
+		tst.Errorf("fail: unexpected counts: %s", prometheusText([]*Metrics{m}))
+	}
+	if n := uint64(len(f.buf.String())); m.Bytes() != n {
+		tst.Errorf("fail: expected %d bytes, but had %d", n, m.Bytes())
+	}
+	l, _ = New(tFailingFacility{}, PriorityInfo, SimpleFormatter, nil, WithMetrics(m), WithWriteErrorHandler(nil, 0))
+	l.Error().Prints("lost")
+	if m.WriteErrors() != 1 {
+		tst.Errorf("fail: expected write error to be counted")
+	}
+	body := string(prometheusText([]*Metrics{m}))
+	for _, s := range []string{
+		"# TYPE slog_records_total counter\n",
+		"slog_records_total{facility=\"test\",priority=\"error\"} 3\n",
+		"slog_write_errors_total{facility=\"test\"} 1\n",
+		"slog_dropped_total{facility=\"test\"} 1\n",
+	} {
+		if !strings.Contains(body, s) {
+			tst.Errorf("fail: expected %q in %q", s, body)
+		}
+	}
+	w := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if body := w.Body.String(); !strings.Contains(body, "slog_records_total{facility=\"test\",priority=\"error\"} ") {
+		tst.Errorf("fail: registered metrics not served: %q", body)
+	}
+	if v, _ := json.Marshal(MetricsVars()); !strings.Contains(string(v), `"test":`) {
+		tst.Errorf("fail: registered metrics not in vars: %s", v)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Custom priorities are numbered from here on, well above any practical
+// trace detail level.
+const priorityCustomBase Priority = 1 << 24
+
+type cPriority struct {
+	name     string
+	tag      string
+	severity Priority
+}
+
+var (
+	customPrioritiesMu sync.RWMutex
+	customPriorities   = make(map[Priority]*cPriority)
+)
+
+// RegisterPriority registers a custom named priority that is ordered with,
+// and enabled together with, the specified builtin severity. Records logged
+// with it are prefixed with its own tag instead of that of its severity.
+// Custom priorities should be registered before creating loggers,
+// typically in init functions.
+func RegisterPriority(name string, tag string, severity Priority) (Priority, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if len(name) == 0 {
+		return severity, fmt.Errorf("empty priority name")
+	}
+	if severity < PriorityEmergency || severity > PriorityTrace {
+		return severity, fmt.Errorf("unsupported severity for priority %q: %d", name, severity)
+	}
+	if !strings.HasSuffix(tag, " ") {
+		tag += " "
+	}
+	customPrioritiesMu.Lock()
+	defer customPrioritiesMu.Unlock()
+	_, builtin := builtinPriority(name)
+	if _, ok := customPriorityNamed(name); ok || builtin {
+		return severity, fmt.Errorf("priority already registered: %q", name)
+	}
+	pri := priorityCustomBase + Priority(len(customPriorities))
+	customPriorities[pri] = &cPriority{name: name, tag: tag, severity: severity}
+	return pri, nil
+}
+
+// CustomPriorities returns all registered custom priorities.
+func CustomPriorities() []Priority {
+	customPrioritiesMu.RLock()
+	defer customPrioritiesMu.RUnlock()
+	res := make([]Priority, 0, len(customPriorities))
+	for pri := priorityCustomBase; pri < priorityCustomBase+Priority(len(customPriorities)); pri++ {
+		res = append(res, pri)
+	}
+	return res
+}
+
+func customPriority(pri Priority) *cPriority {
+	if pri < priorityCustomBase {
+		return nil
+	}
+	customPrioritiesMu.RLock()
+	defer customPrioritiesMu.RUnlock()
+	return customPriorities[pri]
+}
+
+func customPriorityByName(name string) (Priority, bool) {
+	customPrioritiesMu.RLock()
+	defer customPrioritiesMu.RUnlock()
+	return customPriorityNamed(name)
+}
+
+// customPriorityNamed is customPriorityByName for callers holding the lock.
+func customPriorityNamed(name string) (Priority, bool) {
+	for pri, c := range customPriorities {
+		if c.name == name {
+			return pri, true
+		}
+	}
+	return 0, false
+}
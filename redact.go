@@ -0,0 +1,387 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Redactor is implemented by values that know how to redact themselves,
+// such as password or token types. Redact returns the value to be logged
+// in place of the original.
+type Redactor interface {
+	Redact() interface{}
+}
+
+// How redacted values are rendered.
+type RedactMode int
+
+const (
+	// Replace the value with "[REDACTED]".
+	RedactReplace RedactMode = iota
+	// Replace the value with its HMAC-SHA256, so that occurrences of the
+	// same value can still be correlated.
+	RedactHash
+	// Mask all but the last four characters.
+	RedactMask
+)
+
+const redacted = "[REDACTED]"
+
+// Patterns for common secrets in value content.
+var (
+	RedactEmails       = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	RedactCardNumbers  = regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`)
+	RedactBearerTokens = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`)
+)
+
+type redactKey struct {
+	glob string
+	mode RedactMode
+}
+
+type redactPattern struct {
+	re   *regexp.Regexp
+	mode RedactMode
+}
+
+// Redaction masks secrets in records before they reach formatters.
+// Values are redacted if they implement Redactor, if their keys match one
+// of the key globs, or, for string values, where their content matches
+// one of the patterns. Values of matching keys are redacted whole, and
+// fields of other structs, maps and groups are redacted the same way. Patterns also apply to messages, error messages and unstructured output.
+type Redaction struct {
+	mux      sync.RWMutex
+	hmacKey  []byte
+	keys     []redactKey
+	patterns []redactPattern
+	count    uint64
+}
+
+// NewRedaction creates an empty redaction policy. The key is used for
+// RedactHash mode.
+func NewRedaction(hmacKey []byte) *Redaction {
+	return &Redaction{hmacKey: hmacKey}
+}
+
+// Key redacts values of keys matching case insensitive glob pattern,
+// as in path.Match, e.g. "password" or "*token*".
+func (this *Redaction) Key(glob string, mode RedactMode) *Redaction {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.keys = append(this.keys, redactKey{glob: strings.ToLower(glob), mode: mode})
+	return this
+}
+
+// Pattern redacts parts of string values and messages matching re.
+// Card number matches are only redacted if they pass Luhn check.
+func (this *Redaction) Pattern(re *regexp.Regexp, mode RedactMode) *Redaction {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.patterns = append(this.patterns, redactPattern{re: re, mode: mode})
+	return this
+}
+
+// Count returns the number of redactions applied so far.
+func (this *Redaction) Count() uint64 {
+	return atomic.LoadUint64(&this.count)
+}
+
+// apply returns message and values with secrets redacted. Values are
+// copied only when there is something to redact.
+func (this *Redaction) apply(message string, v []interface{}) (string, []interface{}) {
+	this.mux.RLock()
+	defer this.mux.RUnlock()
+	v, _ = this.redactValues(v)
+	return this.redactContent(message), v
+}
+
+// redactValues redacts values, including those in groups of fields,
+// and reports whether there were any.
+func (this *Redaction) redactValues(v []interface{}) ([]interface{}, bool) {
+	var res []interface{}
+	for i, val := range v {
+		nv, changed := val, false
+		if mode, ok := this.valueMode(v, i); ok {
+			// Whole value of a matching key, nested fields included
+			nv, changed = this.redact(asString(val), mode), true
+			atomic.AddUint64(&this.count, 1)
+		} else if fs, ok := val.(Fields); ok {
+			var nfs []interface{}
+			nfs, changed = this.redactValues(fs)
+			nv = Fields(nfs)
+		} else if vs, ok := val.([]interface{}); ok {
+			nv, changed = this.redactElems(vs)
+		} else if r, ok := val.(Redactor); ok {
+			nv, changed = encodeValue(r.Redact()), true
+			atomic.AddUint64(&this.count, 1)
+		}
+		if s, ok := val.(string); ok && !changed {
+			if ns := this.redactContent(s); ns != s {
+				nv, changed = ns, true
+			}
+		}
+		if changed {
+			if res == nil {
+				res = append(make([]interface{}, 0, len(v)), v...)
+			}
+			res[i] = nv
+		}
+	}
+	if res == nil {
+		return v, false
+	}
+	return res, true
+}
+
+// redactElems redacts elements of a slice value, which have no keys,
+// and reports whether there were any.
+func (this *Redaction) redactElems(v []interface{}) ([]interface{}, bool) {
+	var res []interface{}
+	for i, val := range v {
+		nv, changed := val, false
+		switch e := val.(type) {
+		case Fields:
+			var nfs []interface{}
+			nfs, changed = this.redactValues(e)
+			nv = Fields(nfs)
+		case []interface{}:
+			nv, changed = this.redactElems(e)
+		case Redactor:
+			nv, changed = encodeValue(e.Redact()), true
+			atomic.AddUint64(&this.count, 1)
+		case string:
+			if ns := this.redactContent(e); ns != e {
+				nv, changed = ns, true
+			}
+		}
+		if changed {
+			if res == nil {
+				res = append(make([]interface{}, 0, len(v)), v...)
+			}
+			res[i] = nv
+		}
+	}
+	if res == nil {
+		return v, false
+	}
+	return res, true
+}
+
+// redactErrors returns errors with their messages redacted. Errors are
+// replaced only when there is something to redact.
+func (this *Redaction) redactErrors(es []error) []error {
+	this.mux.RLock()
+	defer this.mux.RUnlock()
+	if len(this.patterns) == 0 {
+		return es
+	}
+	var res []error
+	for i, e := range es {
+		if e == nil {
+			continue
+		}
+		if ne, changed := this.redactError(e); changed {
+			if res == nil {
+				res = append(make([]error, 0, len(es)), es...)
+			}
+			res[i] = ne
+		}
+	}
+	if res == nil {
+		return es
+	}
+	return res
+}
+
+// redactError returns a copy of e and of the errors it wraps or joins
+// with their messages redacted, and reports whether there were any.
+func (this *Redaction) redactError(e error) (error, bool) {
+	var next error
+	var joined []error
+	changed := false
+	if j, ok := e.(interface{ Unwrap() []error }); ok {
+		for _, je := range j.Unwrap() {
+			if je != nil {
+				nje, c := this.redactError(je)
+				joined, changed = append(joined, nje), changed || c
+			}
+		}
+	} else if w := errors.Unwrap(e); w != nil {
+		next, changed = this.redactError(w)
+	}
+	s := e.Error()
+	ns := this.redactContent(s)
+	if ns == s && !changed {
+		return e, false
+	}
+	res := &redactedError{orig: e, msg: ns, next: next}
+	if joined != nil {
+		return &redactedJoin{res, joined}, true
+	}
+	return res, true
+}
+
+// redactedError stands in for an error with its message redacted.
+// It matches the original error in errors.Is and errors.As, and
+// formatters report the original's type and stack trace.
+type redactedError struct {
+	orig error
+	msg  string
+	next error
+}
+
+func (this *redactedError) Error() string {
+	return this.msg
+}
+
+func (this *redactedError) Unwrap() error {
+	return this.next
+}
+
+func (this *redactedError) Is(target error) bool {
+	return errors.Is(this.orig, target)
+}
+
+func (this *redactedError) As(target interface{}) bool {
+	return errors.As(this.orig, target)
+}
+
+func (this *redactedError) StackTrace() []uintptr {
+	return stackTrace(this.orig)
+}
+
+// redactedJoin stands in for an error that joins several errors.
+type redactedJoin struct {
+	*redactedError
+	errs []error
+}
+
+func (this *redactedJoin) Unwrap() []error {
+	return this.errs
+}
+
+// originalError returns the error that e stands in for, if it is
+// a redacted one.
+func originalError(e error) error {
+	switch r := e.(type) {
+	case *redactedError:
+		return r.orig
+	case *redactedJoin:
+		return r.orig
+	}
+	return e
+}
+
+// keyOf returns the key for the value at index i.
+func keyOf(v []interface{}, i int) (string, bool) {
+	if i&1 == 0 {
+		return "", false
+	}
+	k, ok := v[i-1].(string)
+	return k, ok
+}
+
+// valueMode returns redaction mode of value i in v if its key matches.
+func (this *Redaction) valueMode(v []interface{}, i int) (RedactMode, bool) {
+	if k, ok := keyOf(v, i); ok {
+		return this.keyMode(k)
+	}
+	return RedactReplace, false
+}
+
+func (this *Redaction) keyMode(key string) (RedactMode, bool) {
+	key = strings.ToLower(key)
+	for _, k := range this.keys {
+		if ok, _ := path.Match(k.glob, key); ok {
+			return k.mode, true
+		}
+	}
+	return RedactReplace, false
+}
+
+func (this *Redaction) redactContent(s string) string {
+	for _, p := range this.patterns {
+		s = p.re.ReplaceAllStringFunc(s, func(m string) string {
+			if p.re == RedactCardNumbers && !luhn(m) {
+				return m
+			}
+			atomic.AddUint64(&this.count, 1)
+			return this.redact(m, p.mode)
+		})
+	}
+	return s
+}
+
+func (this *Redaction) redact(s string, mode RedactMode) string {
+	switch mode {
+	case RedactHash:
+		mac := hmac.New(sha256.New, this.hmacKey)
+		mac.Write([]byte(s))
+		return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:16])
+	case RedactMask:
+		rs := []rune(s)
+		keep := 4
+		if len(rs) <= 2*keep {
+			keep = 0
+		}
+		return strings.Repeat("*", len(rs)-keep) + string(rs[len(rs)-keep:])
+	}
+	return redacted
+}
+
+// luhn validates card number checksum, ignoring separators.
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n&1 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
+
+var (
+	redactionMu sync.RWMutex
+	redaction   *Redaction
+)
+
+// SetRedaction sets redaction policy for loggers that were not given one
+// of their own with WithRedaction option. Nil disables redaction.
+func SetRedaction(r *Redaction) {
+	redactionMu.Lock()
+	defer redactionMu.Unlock()
+	redaction = r
+}
+
+// DefaultRedaction returns the policy set with SetRedaction.
+func DefaultRedaction() *Redaction {
+	redactionMu.RLock()
+	defer redactionMu.RUnlock()
+	return redaction
+}
+
+// WithRedaction makes the logger redact values with r instead of
+// the default redaction policy.
+func WithRedaction(r *Redaction) Option {
+	return func(l *sLogger) {
+		l.redaction = r
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type tPassword string
+
+func (this tPassword) Redact() interface{} {
+	return "***"
+}
+
+func TestRedaction(tst *testing.T) {
+	r := NewRedaction([]byte("key")).
+		Key("password", RedactReplace).
+		Key("*token*", RedactMask).
+		Key("user", RedactHash).
+		Pattern(RedactEmails, RedactReplace).
+		Pattern(RedactCardNumbers, RedactMask).
+		Pattern(RedactBearerTokens, RedactReplace)
+	f := &tFacility{}
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil, WithRedaction(r))
+	v := []interface{}{"Password", "hunter2", "api_token", "abcdef123456", "pin", tPassword("1234")}
+	l.Info().Prints("login", v...)
+	l.Info().Prints("mail to joe@example.com", "card", "4111 1111 1111 1111", "order", "1234 5678 9012 3456", "auth", "Bearer abc.def")
+	l.Info().Prints("user", "user", "joe")
+	res := f.lines()
+	exp := []string{
+		"INFO login Password=[REDACTED] api_token=********3456 pin=***",
+		"INFO mail to [REDACTED] card=***************1111 order=1234 5678 9012 3456 auth=[REDACTED]",
+	}
+	if len(res) != 3 || strings.Join(res[:2], "\n") != strings.Join(exp, "\n") {
+		tst.Errorf("fail: expected %q, but had %q", exp, res)
+	}
+	if len(res) == 3 && (!strings.HasPrefix(res[2], "INFO user user=hmac:") || len(res[2]) != len("INFO user user=hmac:")+32) {
+		tst.Errorf("fail: unexpected hash %q", res[2])
+	}
+	if v[1] != "hunter2" {
+		tst.Errorf("fail: caller's values modified")
+	}
+	if r.Count() != 7 {
+		tst.Errorf("fail: expected 7 redactions, but had %d", r.Count())
+	}
+	l.WithGroup("db").Info().Prints("connect", Group("auth", "password", "x"))
+	if res := f.lines(); len(res) != 1 || res[0] != "INFO connect db.auth.password=[REDACTED]" {
+		tst.Errorf("fail: grouped value not redacted: %q", res)
+	}
+}
+
+type tCreds struct {
+	User     string
+	Password string `log:"password"`
+}
+
+func TestRedactionNested(tst *testing.T) {
+	r := NewRedaction(nil).Key("password", RedactReplace).Key("*token*", RedactReplace)
+	f := &tFacility{}
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil, WithRedaction(r))
+	creds := tCreds{User: "joe", Password: "hunter2"}
+	l.Info().Prints("login", "creds", creds, "ptr", &creds)
+	l.Info().Prints("login", "form", map[string]string{"user": "joe", "password": "hunter2"})
+	exp := []string{
+		"INFO login creds.User=joe creds.password=[REDACTED] ptr.User=joe ptr.password=[REDACTED]",
+		"INFO login form.password=[REDACTED] form.user=joe",
+	}
+	if res := f.lines(); strings.Join(res, "|") != strings.Join(exp, "|") {
+		tst.Errorf("fail: expected %q, but had %q", exp, res)
+	}
+	l.Info().Prints("whole", "tokens", []string{"s1", "s2"}, "password", map[string]string{"old": "hunter2"}, "api_token", struct{ V string }{"abc"})
+	if res := f.lines(); len(res) != 1 || res[0] != "INFO whole tokens=[REDACTED] password=[REDACTED] api_token=[REDACTED]" {
+		tst.Errorf("fail: values of matching keys not redacted whole: %q", res)
+	}
+	if creds.Password != "hunter2" {
+		tst.Errorf("fail: caller's struct modified")
+	}
+}
+
+type tSession struct {
+	id, token string
+}
+
+func (this *tSession) MarshalLog() interface{} {
+	return Fields{"id", this.id, "token", this.token}
+}
+
+func TestRedactionMarshaled(tst *testing.T) {
+	r := NewRedaction(nil).Key("token", RedactReplace).Pattern(RedactEmails, RedactReplace)
+	f := &tFacility{}
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil, WithRedaction(r))
+	s := &tSession{id: "7", token: "abc"}
+	l.Info().Prints("session", "s", s, "all", []interface{}{s, "joe@example.com"})
+	if res := f.lines(); len(res) != 1 || res[0] != "INFO session s.id=7 s.token=[REDACTED] all=[{id=7 token=[REDACTED]} [REDACTED]]" {
+		tst.Errorf("fail: marshaled value not redacted: %q", res)
+	}
+}
+
+func TestRedactionErrors(tst *testing.T) {
+	r := NewRedaction(nil).Pattern(RedactEmails, RedactReplace)
+	f := &tFacility{}
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil, WithRedaction(r))
+	err := errors.New("no user joe@example.com")
+	l.On(fmt.Errorf("lookup: %w", err)).Error().Prints("lookup", "cause", err)
+	l.Info().Printf("mail to %s", "joe@example.com")
+	l.Info().Println("mail to", "joe@example.com")
+	l.Info().Output(1, "mail to joe@example.com")
+	exp := []string{
+		"ERROR lookup cause=no user [REDACTED] - error=lookup: no user [REDACTED] [*fmt.wrapError > *errors.errorString]",
+		"INFO mail to [REDACTED]",
+		"INFO mail to [REDACTED]",
+		"INFO mail to [REDACTED]",
+	}
+	if res := f.lines(); strings.Join(res, "|") != strings.Join(exp, "|") {
+		tst.Errorf("fail: expected %q, but had %q", exp, res)
+	}
+	if e := r.redactErrors([]error{err})[0]; !errors.Is(e, err) || e.Error() != "no user [REDACTED]" {
+		tst.Errorf("fail: unexpected redacted error %v", e)
+	}
+	if err.Error() != "no user joe@example.com" {
+		tst.Errorf("fail: caller's error modified")
+	}
+}
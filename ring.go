@@ -0,0 +1,238 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Ring file layout. The file starts with a header of ringHeaderLen bytes:
+// magic, size of the data area, offset of the next record in it and
+// sequence number of the next record. Records in the data area are
+// aligned to 8 bytes and consist of record magic, payload length,
+// sequence number and CRC-32C of sequence number and payload, followed
+// by the payload. Record magic with ringWrap length marks the end of
+// records before the writer wrapped around.
+const (
+	ringMagic        = "SLOGRNG1"
+	ringHeaderLen    = 64
+	ringRecordMagic  = 0x474f4c53
+	ringRecordHeader = 24
+	ringWrap         = 0xffffffff
+)
+
+// Default size of the data area of ring files.
+const DefaultRingSize = 4 << 20
+
+var (
+	errRingFormat = errors.New("not a ring file")
+	ringTable     = crc32.MakeTable(crc32.Castagnoli)
+)
+
+type fRing struct {
+	path string
+	size int
+	mux  sync.Mutex
+	file *os.File
+	data []byte
+	head int
+	seq  uint64
+}
+
+// NewRingFacility creates a facility that writes records to a ring file
+// at path memory-mapped into the process, with data area of size bytes.
+// Records that do not fit overwrite the oldest ones. As the file is
+// written through the mapping, the records survive the process dying
+// abruptly, e.g. killed by the OOM killer, without syncing each write;
+// Flush commits them to stable storage. An existing ring file of the same
+// size is continued. Use ReadRingFile to recover the records.
+// The facility is only supported on platforms with mmap.
+func NewRingFacility(path string, size int) (Facility, error) {
+	if size <= 0 {
+		size = DefaultRingSize
+	}
+	if size < 2*ringRecordHeader {
+		size = 2 * ringRecordHeader
+	}
+	return &fRing{path: path, size: align8(size)}, nil
+}
+
+func align8(n int) int {
+	return (n + 7) &^ 7
+}
+
+func (this *fRing) OpenLogs(level Priority) (map[Priority]*log.Logger, error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.data == nil {
+		if err := this.open(); err != nil {
+			return nil, err
+		}
+	}
+	res := make(map[Priority]*log.Logger, prioritiesCount)
+	for pri := PriorityEmergency; pri <= PriorityTrace; pri++ {
+		if pri <= level {
+			res[pri] = log.New(this, pri.Tag(), log.Ldate|log.Ltime|log.Lmicroseconds)
+		} else {
+			res[pri] = drain.Logger()
+		}
+	}
+	return res, nil
+}
+
+func (this *fRing) open() error {
+	f, err := os.OpenFile(this.path, os.O_RDWR|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	total := ringHeaderLen + this.size
+	fi, err := f.Stat()
+	if err == nil && fi.Size() != int64(total) {
+		if err = f.Truncate(0); err == nil {
+			err = f.Truncate(int64(total))
+		}
+	}
+	var data []byte
+	if err == nil {
+		data, err = mapFile(f, total)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	head := binary.LittleEndian.Uint64(data[16:])
+	if string(data[:8]) != ringMagic || binary.LittleEndian.Uint64(data[8:]) != uint64(this.size) || head >= uint64(this.size) || head%8 != 0 {
+		for i := range data {
+			data[i] = 0
+		}
+		copy(data, ringMagic)
+		binary.LittleEndian.PutUint64(data[8:], uint64(this.size))
+		head = 0
+	}
+	this.file, this.data = f, data
+	this.head, this.seq = int(head), binary.LittleEndian.Uint64(data[24:])
+	return nil
+}
+
+func (this *fRing) Write(p []byte) (int, error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.data == nil {
+		return 0, fmt.Errorf("not open: %s", this.path)
+	}
+	n := len(p)
+	if max := this.size - ringRecordHeader; len(p) > max {
+		p = p[:max]
+	}
+	total := align8(ringRecordHeader + len(p))
+	d := this.data[ringHeaderLen:]
+	if this.head+total > this.size {
+		binary.LittleEndian.PutUint32(d[this.head:], ringRecordMagic)
+		binary.LittleEndian.PutUint32(d[this.head+4:], ringWrap)
+		this.head = 0
+	}
+	r := d[this.head : this.head+total]
+	binary.LittleEndian.PutUint32(r, ringRecordMagic)
+	binary.LittleEndian.PutUint32(r[4:], uint32(len(p)))
+	binary.LittleEndian.PutUint64(r[8:], this.seq)
+	copy(r[ringRecordHeader:], p)
+	binary.LittleEndian.PutUint32(r[16:], crc32.Update(crc32.Checksum(r[8:16], ringTable), ringTable, p))
+	this.head += total
+	if this.head == this.size {
+		this.head = 0
+	}
+	this.seq++
+	binary.LittleEndian.PutUint64(this.data[16:], uint64(this.head))
+	binary.LittleEndian.PutUint64(this.data[24:], this.seq)
+	return n, nil
+}
+
+// Reopen does nothing, as ring files are not rotated.
+func (this *fRing) Reopen() error {
+	return nil
+}
+
+// Flush commits the ring file to stable storage.
+func (this *fRing) Flush() error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.file == nil {
+		return nil
+	}
+	return this.file.Sync()
+}
+
+func (this *fRing) Close() error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.file == nil {
+		return nil
+	}
+	err := unmapFile(this.data)
+	if err2 := this.file.Close(); err == nil {
+		err = err2
+	}
+	this.file, this.data = nil, nil
+	return err
+}
+
+// RingRecord is a record recovered from a ring file.
+type RingRecord struct {
+	Seq  uint64
+	Line string
+}
+
+// ReadRingFile recovers intact records from the ring file at path, oldest
+// first. Records torn by a crash or partially overwritten are skipped.
+func ReadRingFile(path string) ([]RingRecord, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < ringHeaderLen || string(b[:8]) != ringMagic {
+		return nil, errRingFormat
+	}
+	size := binary.LittleEndian.Uint64(b[8:])
+	if size != uint64(len(b)-ringHeaderLen) {
+		return nil, errRingFormat
+	}
+	d := b[ringHeaderLen:]
+	var res []RingRecord
+	for p := 0; p+ringRecordHeader <= len(d); {
+		if r, n, ok := ringRecordAt(d, p); ok {
+			res = append(res, r)
+			p += n
+		} else {
+			p += 8
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Seq < res[j].Seq })
+	return res, nil
+}
+
+// ringRecordAt validates the record at offset p of data area d, and
+// returns it with its aligned length.
+func ringRecordAt(d []byte, p int) (RingRecord, int, bool) {
+	if binary.LittleEndian.Uint32(d[p:]) != ringRecordMagic {
+		return RingRecord{}, 0, false
+	}
+	n := binary.LittleEndian.Uint32(d[p+4:])
+	if n == ringWrap || int64(n) > int64(len(d)-p-ringRecordHeader) {
+		return RingRecord{}, 0, false
+	}
+	payload := d[p+ringRecordHeader : p+ringRecordHeader+int(n)]
+	if binary.LittleEndian.Uint32(d[p+16:]) != crc32.Update(crc32.Checksum(d[p+8:p+16], ringTable), ringTable, payload) {
+		return RingRecord{}, 0, false
+	}
+	r := RingRecord{Seq: binary.LittleEndian.Uint64(d[p+8:]), Line: string(bytes.TrimSuffix(payload, []byte{'\n'}))}
+	return r, align8(ringRecordHeader + int(n)), true
+}
@@ -0,0 +1,19 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package slog
+
+import (
+	"errors"
+	"os"
+)
+
+func mapFile(f *os.File, size int) ([]byte, error) {
+	return nil, errors.New("ring facility is not supported on this platform")
+}
+
+func unmapFile(b []byte) error {
+	return nil
+}
@@ -0,0 +1,58 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRingFacility(tst *testing.T) {
+	path := filepath.Join(tst.TempDir(), "ring.log")
+	f, _ := NewRingFacility(path, 512)
+	l, err := New(f, PriorityInfo, SimpleFormatter, nil)
+	if err != nil {
+		tst.Skipf("ring facility: %v", err)
+	}
+	for i := 0; i < 40; i++ {
+		l.Info().Prints("record", "i", i)
+	}
+	// Not closed, as if the process died
+	rs, err := ReadRingFile(path)
+	if err != nil || len(rs) < 3 || len(rs) > 10 {
+		tst.Fatalf("fail: unexpected records %v, %v", rs, err)
+	}
+	for i, r := range rs {
+		exp := fmt.Sprintf("record i=%d", 40-len(rs)+i)
+		if r.Seq != uint64(40-len(rs)+i) || !strings.HasPrefix(r.Line, "INFO ") || !strings.HasSuffix(r.Line, exp) {
+			tst.Errorf("fail: expected record %d %q, but had %d %q", 40-len(rs)+i, exp, r.Seq, r.Line)
+		}
+	}
+
+	// Torn record is skipped
+	b, _ := ioutil.ReadFile(path)
+	i := strings.Index(string(b), "record i=39")
+	b[i] = 'X'
+	ioutil.WriteFile(path, b, 0640)
+	if res, _ := ReadRingFile(path); len(res) != len(rs)-1 || res[len(res)-1].Seq != 38 {
+		tst.Errorf("fail: expected torn record to be skipped, but had %v", res)
+	}
+
+	// Existing file is continued
+	f.(io.Closer).Close()
+	f, _ = NewRingFacility(path, 512)
+	l, _ = New(f, PriorityInfo, SimpleFormatter, nil)
+	l.Info().Prints("continued")
+	f.(io.Closer).Close()
+	if res, _ := ReadRingFile(path); len(res) == 0 || res[len(res)-1].Seq != 40 || !strings.HasSuffix(res[len(res)-1].Line, "continued") {
+		tst.Errorf("fail: expected continued sequence, but had %v", res)
+	}
+
+	if _, err := ReadRingFile(filepath.Join(tst.TempDir(), "missing")); err == nil {
+		tst.Errorf("fail: expected error for missing file")
+	}
+}
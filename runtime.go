@@ -3,18 +3,29 @@
 package slog
 
 import (
+	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 )
 
-var (
-	rtLevel        = "info"
-	rtTrace   uint = 0
-	rtModules      = ""
-	rtFormat       = "simple"
-	rtLog          = "stderr"
-)
+// Runtime configuration defaults to the values of SLOG_* environment
+// variables, which in turn can be overridden with command line flags.
+var rtConfig = Config{
+	Level:  envString("SLOG_LEVEL", "info"),
+	Trace:  envUint("SLOG_TRACE", 0),
+	Filter: splitList(envString("SLOG_TRACE_FILTER", "")),
+	Format: envString("SLOG_FMT", "simple"),
+	Log:    splitList(envString("SLOG_LOG", "stderr")),
+	Sample: envString("SLOG_SAMPLE", ""),
+}
+
+var rtConfigPath = envString("SLOG_CONFIG", "")
+
+// Invalid values of environment variables, reported once the shared
+// logger is created.
+var envErrors []error
 
 var newSyslogFacility func(Priority) (Facility, error)
 
@@ -23,13 +34,32 @@ var (
 	sharedFacility   Facility
 	sharedLoggerMu   = &sync.Mutex{}
 	sharedLogger     Logger
+	sharedLog        []string
+	sharedBase       *Config
+	sharedWatchOnce  sync.Once
 )
 
 func SharedLogger() Logger {
+	sharedWatchOnce.Do(watchSharedConfig)
+	return defaultLogger()
+}
+
+func defaultLogger() Logger {
 	sharedLoggerMu.Lock()
 	defer sharedLoggerMu.Unlock()
 	if sharedLogger == nil {
-		sharedLogger, _ = New(SharedFacility(), DefaultLevel(), DefaultFormatter(), DefaultFilter())
+		opts, _ := rtConfig.options()
+		sharedLogger, _ = New(SharedFacility(), DefaultLevel(), DefaultFormatter(), DefaultFilter(), opts...)
+		errs := envErrors
+		if err := rtConfig.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+		for _, err := range errs {
+			if sharedLogger == nil {
+				break
+			}
+			sharedLogger.On(err).Prints("Invalid logging configuration, using defaults")
+		}
 	}
 	return sharedLogger
 }
@@ -38,60 +68,131 @@ func SharedFacility() Facility {
 	sharedFacilityMu.Lock()
 	defer sharedFacilityMu.Unlock()
 	if sharedFacility == nil {
-		switch rtLog {
+		sharedLog = rtConfig.Log
+		sharedFacility, _ = newFacility(sharedLog, DefaultLevel())
+	}
+	return sharedFacility
+}
+
+func DefaultLevel() Priority {
+	if pri, err := parseLevel(rtConfig.Level, rtConfig.Trace); err == nil {
+		return pri
+	}
+	return PriorityInfo
+}
+
+func DefaultFilter() []string {
+	return append(make([]string, 0, len(rtConfig.Filter)), rtConfig.Filter...)
+}
+
+func DefaultFormatter() Formatter {
+	if f, err := parseFormat(rtConfig.Format); err == nil {
+		return f
+	}
+	return SimpleFormatter
+}
+
+func parseLevel(level string, trace uint) (Priority, error) {
+	if trace > 0 {
+		return Priority(PriorityTrace + Priority(trace-1)), nil
+	}
+	if len(level) == 0 {
+		return PriorityInfo, nil
+	}
+	return ParsePriority(level)
+}
+
+// parseFormat returns formatter for format name. Formats containing
+// percent signs are layout patterns, for which SimpleFormatter is returned
+// and the layout is set up with WithLayout option.
+func parseFormat(format string) (Formatter, error) {
+	if strings.Contains(format, "%") {
+		if _, err := ParseLayout(format); err != nil {
+			return nil, err
+		}
+		return SimpleFormatter, nil
+	}
+	switch format {
+	case "simple", "":
+		return SimpleFormatter, nil
+	case "json":
+		return CompactJsonFormatter, nil
+	case "json-pretty":
+		return PrettyJsonFormatter, nil
+	}
+	return nil, fmt.Errorf("unsupported logging format: %q", format)
+}
+
+// newFacility creates a facility for the specified destinations.
+// Multiple destinations are combined with a tee facility. Sole standard
+// stream destination attached to a terminal gets a console facility.
+func newFacility(dests []string, level Priority) (Facility, error) {
+	if len(dests) == 0 {
+		return NewStdFacility(os.Stderr)
+	}
+	fs := make([]Facility, 0, len(dests))
+	for _, dest := range dests {
+		var f Facility
+		var err error
+		switch dest {
 		case "stdout":
-			sharedFacility, _ = NewStdFacility(os.Stdout)
+			f, err = newStreamFacility(os.Stdout, len(dests) == 1)
 		case "stderr":
-			sharedFacility, _ = NewStdFacility(os.Stderr)
+			f, err = newStreamFacility(os.Stderr, len(dests) == 1)
 		case "syslog":
-			if newSyslogFacility != nil {
-				sharedFacility, _ = newSyslogFacility(DefaultLevel())
+			if newSyslogFacility == nil {
+				err = fmt.Errorf("syslog is not supported on this platform")
+			} else {
+				f, err = newSyslogFacility(level)
 			}
 		default:
-			sharedFacility, _ = NewFileFacility(rtLog)
+			f, err = NewFileFacility(dest)
+		}
+		if err != nil {
+			closeFacilities(fs)
+			return nil, err
 		}
+		fs = append(fs, f)
 	}
-	return sharedFacility
+	if len(fs) == 1 {
+		return fs[0], nil
+	}
+	return NewTeeFacility(fs...)
 }
 
-func DefaultLevel() Priority {
-	if rtTrace > 0 {
-		return Priority(PriorityTrace + Priority(rtTrace-1))
-	}
-	switch rtLevel {
-	case "error":
-		return PriorityError
-	case "warn":
-		return PriorityWarn
-	case "notice":
-		return PriorityNotice
-	case "info", "":
-		return PriorityInfo
-	default:
-		return PriorityInfo
+func newStreamFacility(file *os.File, console bool) (Facility, error) {
+	if console && isTerminal(file) {
+		return NewConsoleFacility(file)
 	}
+	return NewStdFacility(file)
 }
 
-func DefaultFilter() []string {
+func splitList(s string) []string {
 	res := make([]string, 0)
-	if len(rtModules) > 0 {
-		res = strings.Split(rtModules, ",")
-		for i, _ := range res {
-			res[i] = strings.TrimSpace(res[i])
+	if len(s) > 0 {
+		for _, v := range strings.Split(s, ",") {
+			if v = strings.TrimSpace(v); len(v) > 0 {
+				res = append(res, v)
+			}
 		}
 	}
 	return res
 }
 
-func DefaultFormatter() Formatter {
-	switch rtFormat {
-	case "simple":
-		return SimpleFormatter
-	case "json":
-		return CompactJsonFormatter
-	case "json-pretty":
-		return PrettyJsonFormatter
-	default:
-		return SimpleFormatter
+func envString(name string, def string) string {
+	if v, ok := os.LookupEnv(name); ok {
+		return v
+	}
+	return def
+}
+
+func envUint(name string, def uint) uint {
+	if v, ok := os.LookupEnv(name); ok {
+		n, err := strconv.ParseUint(v, 10, 0)
+		if err == nil {
+			return uint(n)
+		}
+		envErrors = append(envErrors, fmt.Errorf("invalid %s: %q", name, v))
 	}
+	return def
 }
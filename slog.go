@@ -3,15 +3,23 @@
 package slog
 
 import (
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Accessor interface {
 	Log(pri Priority) Log
+	Debug() Log
 	Info() Log
 	Notice() Log
 	Warning() Log
 	Error() Log
+	Critical() Log
+	Alert() Log
+	Emergency() Log
 	Trace(detail int) Log
 }
 
@@ -22,16 +30,30 @@ type Logger interface {
 	On(err ...error) Selector
 	Success() Selector
 	With(err ...error) Selector
+	WithGroup(name string) Logger
+	Flush() error
+	Recover(v ...interface{})
 }
 
 type Log interface {
 	Printe(message string, v ...interface{})
 	Prints(message string, v ...interface{})
+	// Printt logs the record with message rendered from template.
+	// See Message templates.
+	Printt(template string, v ...interface{})
+	// Fatals logs the record, flushes the logger and exits.
 	Fatals(message string, v ...interface{})
 	Logger() *log.Logger
 	ScopedLog(err ...error) Log
 	Offset(stackOffset int) Log
-	prints(calldepth int, message string, v []interface{}, err []error) error
+	// Rate limiting. Records skipped since the previous logged one are
+	// counted in "skipped" field of the next logged record.
+	Once() Log
+	Every(n int) Log
+	EveryDuration(d time.Duration) Log
+	Limit(rate float64, burst int) Log
+	Key(key interface{}) Log
+	prints(calldepth int, message string, v []interface{}, err []error) bool
 	// Shortcuts to log.Logger
 	Output(calldepth int, s string) error
 	Printf(format string, v ...interface{})
@@ -42,37 +64,78 @@ type Log interface {
 type Selector interface {
 	Accessor
 	Prints(message string, v ...interface{})
+	// Printt logs the record like Prints with message rendered from
+	// template.
+	Printt(template string, v ...interface{})
+	// Fatals logs the record like Prints, and if the selector has any
+	// error, whatever priority the error classifier assigns to it,
+	// flushes the logger and exits.
 	Fatals(message string, v ...interface{})
+	// Return logs the record like Prints and returns the selector's
+	// errors marked as logged, so that On and With selectors that come
+	// across them again skip or downgrade the record. Errors are wrapped
+	// with their messages and chains left intact. Errors of records that
+	// are not written, e.g. suppressed by the classifier or below the
+	// level, are returned unmarked.
+	Return(message string, v ...interface{}) error
 	Logger() *log.Logger
 }
 
 // Logging level expressed as a number.
 // Positive numbers correspond to trace detail level.
 // Default is value corresponds to Info logging level.
+// Priorities follow RFC 5424 severities, with all trace detail levels
+// corresponding to Debug severity.
 type Priority int
 
 const (
-	PriorityError Priority = iota
+	PriorityEmergency Priority = iota - 3
+	PriorityAlert
+	PriorityCritical
+	PriorityError
 	PriorityWarn
 	PriorityNotice
 	PriorityInfo
 	PriorityTrace
 )
 
+// PriorityDebug is the same as the first trace detail level.
+const PriorityDebug = PriorityTrace
+
 var priTags = map[Priority]string{
-	PriorityError:  "ERROR ",
-	PriorityWarn:   "WARNING ",
-	PriorityNotice: "NOTICE ",
-	PriorityInfo:   "INFO ",
-	PriorityTrace:  "TRACE ",
+	PriorityEmergency: "EMERGENCY ",
+	PriorityAlert:     "ALERT ",
+	PriorityCritical:  "CRITICAL ",
+	PriorityError:     "ERROR ",
+	PriorityWarn:      "WARNING ",
+	PriorityNotice:    "NOTICE ",
+	PriorityInfo:      "INFO ",
+	PriorityTrace:     "TRACE ",
 }
 
-const prioritiesCount = 5
+const prioritiesCount = 8
+
+var priNames = map[Priority]string{
+	PriorityEmergency: "emergency",
+	PriorityAlert:     "alert",
+	PriorityCritical:  "critical",
+	PriorityError:     "error",
+	PriorityWarn:      "warn",
+	PriorityNotice:    "notice",
+	PriorityInfo:      "info",
+	PriorityTrace:     "trace",
+}
 
+// Bound returns builtin priority this one is logged with.
+// Trace detail levels are bound to PriorityTrace and custom priorities
+// to their severities.
 func (this Priority) Bound() Priority {
+	if c := customPriority(this); c != nil {
+		return c.severity
+	}
 	switch {
-	case this < PriorityError:
-		return PriorityError
+	case this < PriorityEmergency:
+		return PriorityEmergency
 	case this > PriorityTrace:
 		return PriorityTrace
 	}
@@ -80,9 +143,76 @@ func (this Priority) Bound() Priority {
 }
 
 func (this Priority) Tag() string {
+	if c := customPriority(this); c != nil {
+		return c.tag
+	}
 	return priTags[this.Bound()]
 }
 
+// ParsePriority parses priority names produced by Priority.String.
+// Trace verbosity is specified as a number following "trace", e.g. "trace2".
+func ParsePriority(s string) (Priority, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	if pri, ok := customPriorityByName(name); ok {
+		return pri, nil
+	}
+	if pri, ok := builtinPriority(name); ok {
+		return pri, nil
+	}
+	return PriorityInfo, fmt.Errorf("unsupported logging level: %q", s)
+}
+
+// builtinPriority returns builtin priority by its lower case name.
+func builtinPriority(name string) (Priority, bool) {
+	for pri, n := range priNames {
+		if name == n {
+			return pri, true
+		}
+	}
+	switch {
+	case name == "warning":
+		return PriorityWarn, true
+	case name == "debug":
+		return PriorityDebug, true
+	case strings.HasPrefix(name, "trace"):
+		if d, err := strconv.ParseUint(name[len("trace"):], 10, 16); err == nil && d > 0 {
+			return PriorityTrace + Priority(d-1), true
+		}
+	}
+	return PriorityInfo, false
+}
+
+func (this Priority) String() string {
+	if c := customPriority(this); c != nil {
+		return c.name
+	}
+	if this > PriorityTrace {
+		return fmt.Sprintf("%s%d", priNames[PriorityTrace], this-PriorityTrace+1)
+	}
+	return priNames[this.Bound()]
+}
+
+// Set implements flag.Value.
+func (this *Priority) Set(s string) error {
+	pri, err := ParsePriority(s)
+	if err == nil {
+		*this = pri
+	}
+	return err
+}
+
+func (this Priority) MarshalText() ([]byte, error) {
+	return []byte(this.String()), nil
+}
+
+func (this *Priority) UnmarshalText(text []byte) error {
+	return this.Set(string(text))
+}
+
+func Debug() Log {
+	return SharedLogger().Debug()
+}
+
 func Info() Log {
 	return SharedLogger().Info()
 }
@@ -99,6 +229,18 @@ func Error() Log {
 	return SharedLogger().Error()
 }
 
+func Critical() Log {
+	return SharedLogger().Critical()
+}
+
+func Alert() Log {
+	return SharedLogger().Alert()
+}
+
+func Emergency() Log {
+	return SharedLogger().Emergency()
+}
+
 func Trace(detail int) Log {
 	return SharedLogger().Trace(detail)
 }
@@ -114,3 +256,7 @@ func Success() Selector {
 func With(err ...error) Selector {
 	return SharedLogger().With(err...)
 }
+
+func WithGroup(name string) Logger {
+	return SharedLogger().WithGroup(name)
+}
@@ -0,0 +1,153 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// tFacility captures output of all priorities up to the opened level.
+type tFacility struct {
+	buf   bytes.Buffer
+	flags int
+}
+
+func (this *tFacility) OpenLogs(level Priority) (map[Priority]*log.Logger, error) {
+	res := make(map[Priority]*log.Logger, prioritiesCount)
+	for pri := PriorityEmergency; pri <= PriorityTrace; pri++ {
+		if pri <= level {
+			res[pri] = log.New(&this.buf, pri.Tag(), this.flags)
+		} else {
+			res[pri] = drain.Logger()
+		}
+	}
+	return res, nil
+}
+
+func (this *tFacility) Reopen() error {
+	return nil
+}
+
+func (this *tFacility) lines() []string {
+	s := strings.TrimSuffix(this.buf.String(), "\n")
+	this.buf.Reset()
+	if len(s) == 0 {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+func TestPriorityText(tst *testing.T) {
+	for _, t := range []struct {
+		pri Priority
+		s   string
+	}{
+		{PriorityEmergency, "emergency"},
+		{PriorityAlert, "alert"},
+		{PriorityCritical, "critical"},
+		{PriorityError, "error"},
+		{PriorityWarn, "warn"},
+		{PriorityNotice, "notice"},
+		{PriorityInfo, "info"},
+		{PriorityTrace, "trace"},
+		{PriorityTrace + 2, "trace3"},
+	} {
+		var pri Priority
+		if err := pri.UnmarshalText([]byte(t.s)); err != nil || pri != t.pri {
+			tst.Errorf("fail: %q parsed as %d: %v", t.s, pri, err)
+		}
+		if b, _ := t.pri.MarshalText(); string(b) != t.s {
+			tst.Errorf("fail: expected %q, but had %q", t.s, b)
+		}
+	}
+	var pri Priority
+	if err := pri.Set("loud"); err == nil {
+		tst.Errorf("fail: expected error for unknown priority")
+	}
+}
+
+func TestSeverities(tst *testing.T) {
+	f := &tFacility{}
+	l, _ := New(f, PriorityCritical, SimpleFormatter, nil)
+	l.Emergency().Prints("a")
+	l.Alert().Prints("b")
+	l.Critical().Prints("c")
+	l.Error().Prints("d")
+	l.Debug().Prints("e")
+	exp := []string{"EMERGENCY a", "ALERT b", "CRITICAL c"}
+	if res := f.lines(); strings.Join(res, "|") != strings.Join(exp, "|") {
+		tst.Errorf("fail: expected %q, but had %q", exp, res)
+	}
+	l, _ = New(f, PriorityDebug, SimpleFormatter, nil)
+	l.Debug().Prints("e")
+	l.Log(PriorityTrace + 1).Prints("f")
+	if res := f.lines(); len(res) != 1 || res[0] != "TRACE e" {
+		tst.Errorf("fail: expected only debug record, but had %q", res)
+	}
+}
+
+// unregisterPriority removes the last registered custom priority pri.
+func unregisterPriority(pri Priority) {
+	customPrioritiesMu.Lock()
+	defer customPrioritiesMu.Unlock()
+	if pri == priorityCustomBase+Priority(len(customPriorities)-1) {
+		delete(customPriorities, pri)
+	}
+}
+
+func TestCustomPriority(tst *testing.T) {
+	audit, err := RegisterPriority("Audit", "AUDIT", PriorityNotice)
+	if err != nil {
+		tst.Fatal(err)
+	}
+	defer unregisterPriority(audit)
+	if _, err := RegisterPriority("audit", "AUDIT", PriorityNotice); err == nil {
+		tst.Errorf("fail: expected duplicate registration to fail")
+	}
+	if pri, err := ParsePriority("audit"); err != nil || pri != audit || audit.String() != "audit" {
+		tst.Errorf("fail: custom priority name not recognized")
+	}
+	f := &tFacility{}
+	l, _ := New(f, PriorityNotice, SimpleFormatter, nil)
+	l.Log(audit).Prints("login", "user", "joe")
+	if res := f.lines(); len(res) != 1 || res[0] != "AUDIT login user=joe" {
+		tst.Errorf("fail: unexpected output %q", res)
+	}
+	l, _ = New(f, PriorityWarn, SimpleFormatter, nil)
+	l.Log(audit).Prints("login", "user", "joe")
+	if res := f.lines(); len(res) != 0 {
+		tst.Errorf("fail: expected no output, but had %q", res)
+	}
+}
+
+func TestRegisterPriorityConcurrently(tst *testing.T) {
+	errs := make(chan error, 8)
+	var pris []Priority
+	var mux sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pri, err := RegisterPriority("racy", "RACY", PriorityInfo)
+			if err != nil {
+				errs <- err
+				return
+			}
+			mux.Lock()
+			pris = append(pris, pri)
+			mux.Unlock()
+		}()
+	}
+	wg.Wait()
+	for _, pri := range pris {
+		defer unregisterPriority(pri)
+	}
+	if len(pris) != 1 || len(errs) != cap(errs)-1 {
+		tst.Errorf("fail: expected exactly one registration to succeed, but had %d", len(pris))
+	}
+}
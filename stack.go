@@ -0,0 +1,129 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"encoding/json"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+// Stack is a goroutine stack trace attached to a record.
+// It is rendered one frame per line in text formats and as an array of
+// frames in JSON.
+type Stack []uintptr
+
+const maxStackDepth = 64
+
+// Priority threshold that disables stack traces.
+const noStack = PriorityEmergency - 1
+
+// CaptureStack captures stack trace of the calling goroutine. The argument
+// skip is the number of stack frames to skip before recording, with 0
+// identifying the caller of CaptureStack.
+func CaptureStack(skip int) Stack {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip+2, pcs)
+	return Stack(pcs[:n])
+}
+
+func (this Stack) Frames() []string {
+	return stackFrames(this)
+}
+
+func (this Stack) String() string {
+	fs := this.Frames()
+	if len(fs) == 0 {
+		return ""
+	}
+	return "\n\t\t" + strings.Join(fs, "\n\t\t")
+}
+
+func (this Stack) MarshalJSON() ([]byte, error) {
+	return json.Marshal(this.Frames())
+}
+
+// WithStack makes the logger attach stack trace of the logging goroutine
+// to the records with pri or more severe priority.
+func WithStack(pri Priority) Option {
+	return func(l *sLogger) {
+		l.stack = pri
+	}
+}
+
+// Flusher is implemented by facilities that buffer output.
+type Flusher interface {
+	Flush() error
+}
+
+func (this *sLogger) Flush() error {
+	if this.dedup != nil {
+		this.dedup.flush()
+	}
+	if f, ok := this.facility.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// What to do after logging a recovered panic.
+type RecoverMode int32
+
+const (
+	// Panic again with the recovered value. The runtime then reports the
+	// stack of the deferred Recover rather than of the original panic,
+	// which is found in the logged record.
+	RecoverRepanic RecoverMode = iota
+	// Exit with status 2, as the runtime does for unrecovered panics.
+	RecoverExit
+)
+
+var recoverMode int32
+
+// SetRecoverMode sets what Recover does after logging a panic.
+// Default is RecoverRepanic.
+func SetRecoverMode(mode RecoverMode) {
+	atomic.StoreInt32(&recoverMode, int32(mode))
+}
+
+// Recover is meant to be deferred. It recovers a panic, logs its value and
+// stack trace at Error priority along with the key/value pairs in v,
+// flushes the logger, and then panics again or exits depending on
+// SetRecoverMode.
+func (this *sLogger) Recover(v ...interface{}) {
+	if r := recover(); r != nil {
+		recovered(this, r, v)
+	}
+}
+
+// Recover is the same as Logger.Recover for the shared logger.
+func Recover(v ...interface{}) {
+	if r := recover(); r != nil {
+		recovered(SharedLogger(), r, v)
+	}
+}
+
+func recovered(logger Logger, r interface{}, v []interface{}) {
+	var errs []error
+	if err, ok := r.(error); ok {
+		errs = []error{err}
+	} else {
+		v = appendValues(v, "panic", r)
+	}
+	if l, ok := logger.Error().(*sLog); ok {
+		sl := *l
+		sl.stack = true
+		// Stack trace starts with the panic call
+		sl.prints(3, "Recovered panic", v, errs)
+	}
+	if sl, ok := logger.(*sLogger); ok {
+		sl.recorder.crashed("panic")
+	}
+	logger.Flush()
+	if RecoverMode(atomic.LoadInt32(&recoverMode)) == RecoverExit {
+		os.Exit(2)
+	}
+	panic(r)
+}
@@ -0,0 +1,41 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStackPolicy(tst *testing.T) {
+	f := &tFacility{}
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil, WithStack(PriorityWarn))
+	l.Warning().Prints("warn")
+	l.Info().Prints("info")
+	res := f.lines()
+	if len(res) < 3 || res[0] != "WARNING warn stack=" || !strings.HasPrefix(res[1], "\t\tgithub.com/baobabus/slog.TestStackPolicy ") {
+		tst.Errorf("fail: expected stack trace, but had %q", res)
+	}
+	if last := res[len(res)-1]; last != "INFO info" {
+		tst.Errorf("fail: expected no stack trace, but had %q", last)
+	}
+}
+
+func TestRecover(tst *testing.T) {
+	f := &tFacility{}
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil)
+	r := func() (r interface{}) {
+		defer func() {
+			r = recover()
+		}()
+		defer l.Recover("job", 1)
+		panic("boom")
+	}()
+	if r != "boom" {
+		tst.Errorf("fail: expected repanic, but had %v", r)
+	}
+	res := f.lines()
+	if len(res) < 2 || res[0] != "ERROR Recovered panic job=1 panic=boom stack=" || !strings.Contains(res[1], "panic") {
+		tst.Errorf("fail: unexpected output %q", res)
+	}
+}
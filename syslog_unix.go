@@ -27,7 +27,7 @@ func NewSyslogFacility(level Priority) (Facility, error) {
 
 func (this *fSyslog) OpenLogs(level Priority) (map[Priority]*log.Logger, error) {
 	res := make(map[Priority]*log.Logger, prioritiesCount)
-	for pri := PriorityError; pri <= PriorityTrace; pri++ {
+	for pri := PriorityEmergency; pri <= PriorityTrace; pri++ {
 		if pri <= level {
 			// syslog does it's own time and priority stamping,
 			// although the priority is in numeric form, so we'll keep ours
@@ -47,6 +47,10 @@ func (this *fSyslog) Reopen() error {
 	return nil
 }
 
+func (this *fSyslog) Close() error {
+	return this.writer.Close()
+}
+
 type lSyslog struct {
 	writer   *syslog.Writer
 	priority Priority
@@ -55,6 +59,12 @@ type lSyslog struct {
 func (this lSyslog) Write(p []byte) (n int, err error) {
 	err = nil
 	switch this.priority {
+	case PriorityEmergency:
+		err = this.writer.Emerg(string(p))
+	case PriorityAlert:
+		err = this.writer.Alert(string(p))
+	case PriorityCritical:
+		err = this.writer.Crit(string(p))
 	case PriorityError:
 		err = this.writer.Err(string(p))
 	case PriorityWarn:
@@ -0,0 +1,217 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Message templates name the values to be rendered into messages with
+// placeholders in curly braces, e.g. "User {user} logged in from {ip}".
+// Placeholder names are keys of key/value pairs, with dots separating
+// the names of groups, e.g. "{req.id}". Literal braces are doubled.
+//
+// Records logged with templates carry the raw template as "template"
+// field and its hash as "event_type" field, so that occurrences of the
+// same message can be grouped regardless of values. Placeholders without
+// values are left in the message as they are and listed in
+// "template_missing" field. Keys not used in the template are listed in
+// "template_unused" field. Values themselves are logged as fields either
+// way.
+
+type templatePart struct {
+	text string
+	name string
+}
+
+type msgTemplate struct {
+	parts     []templatePart
+	names     map[string]bool
+	eventType string
+}
+
+var (
+	templates     sync.Map
+	templateCount int32
+)
+
+// Limit on the number of cached templates.
+const maxTemplates = 4096
+
+func parseTemplate(s string) *msgTemplate {
+	if t, ok := templates.Load(s); ok {
+		return t.(*msgTemplate)
+	}
+	res := &msgTemplate{names: make(map[string]bool)}
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	res.eventType = fmt.Sprintf("%08x", h.Sum32())
+	text := &bytes.Buffer{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c == '{' || c == '}') && i+1 < len(s) && s[i+1] == c {
+			text.WriteByte(c)
+			i++
+			continue
+		}
+		if c == '{' {
+			if j := strings.IndexByte(s[i:], '}'); j > 1 && isPlaceholder(s[i+1:i+j]) {
+				res.parts = append(res.parts, templatePart{text: text.String()}, templatePart{name: s[i+1 : i+j]})
+				res.names[s[i+1:i+j]] = true
+				text.Reset()
+				i += j
+				continue
+			}
+		}
+		text.WriteByte(c)
+	}
+	if text.Len() > 0 {
+		res.parts = append(res.parts, templatePart{text: text.String()})
+	}
+	for {
+		n := atomic.LoadInt32(&templateCount)
+		if n >= maxTemplates {
+			break
+		}
+		if atomic.CompareAndSwapInt32(&templateCount, n, n+1) {
+			if t, loaded := templates.LoadOrStore(s, res); loaded {
+				atomic.AddInt32(&templateCount, -1)
+				return t.(*msgTemplate)
+			}
+			break
+		}
+	}
+	return res
+}
+
+func isPlaceholder(name string) bool {
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// check returns names of placeholders without values and keys without
+// placeholders.
+func (this *msgTemplate) check(v []interface{}) (missing, unused []string) {
+	for _, p := range this.parts {
+		if len(p.name) > 0 && !contains(missing, p.name) {
+			if _, ok := lookupValue(v, p.name); !ok {
+				missing = append(missing, p.name)
+			}
+		}
+	}
+	for i := 0; i+1 < len(v); i += 2 {
+		if k, ok := v[i].(string); ok && !this.uses(k) && !internalKeys[k] {
+			unused = append(unused, k)
+		}
+	}
+	return missing, unused
+}
+
+// Keys added by selectors, which templates need not use.
+var internalKeys = map[string]bool{"error_rule": true}
+
+// uses reports whether the template has placeholders for key or, if it
+// is a group, for one of its keys.
+func (this *msgTemplate) uses(key string) bool {
+	if this.names[key] {
+		return true
+	}
+	for n := range this.names {
+		if strings.HasPrefix(n, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// render fills the placeholders with values looked up in v.
+func (this *msgTemplate) render(v []interface{}) string {
+	buf := &bytes.Buffer{}
+	for _, p := range this.parts {
+		if len(p.name) == 0 {
+			buf.WriteString(p.text)
+		} else if val, ok := lookupValue(v, p.name); ok {
+			buf.WriteString(asString(val))
+		} else {
+			buf.WriteString("{" + p.name + "}")
+		}
+	}
+	return buf.String()
+}
+
+// lookupValue finds value by its dotted key, descending into groups.
+func lookupValue(v []interface{}, key string) (interface{}, bool) {
+	for i := 0; i+1 < len(v); i += 2 {
+		k, ok := v[i].(string)
+		if !ok {
+			continue
+		}
+		if k == key {
+			return v[i+1], true
+		}
+		if strings.HasPrefix(key, k+".") {
+			if fs, ok := v[i+1].(Fields); ok {
+				if val, ok := lookupValue(fs, key[len(k)+1:]); ok {
+					return val, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// lookupValues returns the values of the innermost group.
+func lookupValues(v []interface{}, groups []string) []interface{} {
+	for _, g := range groups {
+		val, _ := lookupValue(v, g)
+		fs, _ := val.(Fields)
+		v = fs
+	}
+	return v
+}
+
+func appendTemplateIssues(v []interface{}, missing, unused []string) []interface{} {
+	if len(missing) > 0 {
+		v = appendValues(v, "template_missing", missing)
+	}
+	if len(unused) > 0 {
+		v = appendValues(v, "template_unused", unused)
+	}
+	return v
+}
+
+func contains(ss []string, s string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *sLog) Printt(template string, v ...interface{}) {
+	this.printt(2, template, v)
+}
+
+func (this *sLog) printt(calldepth int, template string, v []interface{}) {
+	res := *this
+	res.template = true
+	res.prints(calldepth+1, template, v, this.scope)
+}
+
+func (this *sSelector) Printt(template string, v ...interface{}) {
+	l, v := this.scopedLog(v)
+	if sl, ok := l.(*sLog); ok {
+		sl.printt(2, template, v)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPrintt(tst *testing.T) {
+	f := &tFacility{}
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil)
+	tmpl := "User {user} logged in from {ip} {{ok}}"
+	l.Info().Printt(tmpl, "user", "joe", "ip", "::1")
+	l.Info().Printt(tmpl, "user", "ann", "ip", "10.0.0.1")
+	l.WithGroup("auth").Info().Printt("User {user} ({req.id}) failed", "user", "joe", Group("req", "id", 7), "n", 2)
+	res := f.lines()
+	if len(res) != 3 {
+		tst.Fatalf("fail: unexpected output %q", res)
+	}
+	ev := parseTemplate(tmpl).eventType
+	exp := "INFO User joe logged in from ::1 {ok} user=joe ip=::1 template=" + tmpl + " event_type=" + ev
+	if res[0] != exp {
+		tst.Errorf("fail: expected %q, but had %q", exp, res[0])
+	}
+	if !strings.HasPrefix(res[1], "INFO User ann logged in from 10.0.0.1 {ok} ") || !strings.HasSuffix(res[1], " event_type="+ev) {
+		tst.Errorf("fail: event type not stable: %q", res[1])
+	}
+	exp = "INFO User joe (7) failed auth.user=joe auth.req.id=7 auth.n=2 auth.template_unused=[n]"
+	if !strings.HasPrefix(res[2], exp) {
+		tst.Errorf("fail: expected %q, but had %q", exp, res[2])
+	}
+	l.Info().Printt("User {user} from {ip}", "user", "joe")
+	if res = f.lines(); len(res) != 1 || !strings.HasPrefix(res[0], "INFO User joe from {ip} user=joe template_missing=[ip] ") {
+		tst.Errorf("fail: missing placeholder not reported: %q", res)
+	}
+	l, _ = New(f, PriorityInfo, SimpleFormatter, nil, WithRedaction(NewRedaction(nil).Key("password", RedactReplace)))
+	l.Info().Printt("Login with {password}", "password", "hunter2")
+	if res = f.lines(); len(res) != 1 || strings.Contains(res[0], "hunter2") {
+		tst.Errorf("fail: rendered value not redacted: %q", res)
+	}
+}
+
+func TestSelectorPrintt(tst *testing.T) {
+	f := &tFacility{}
+	c := NewErrorClassifier(ErrorRule{Name: "short", Match: ErrorIs(io.ErrUnexpectedEOF), Priority: PriorityWarn})
+	l, _ := New(f, PriorityInfo, SimpleFormatter, nil, WithErrorClassifier(c))
+	l.On(io.EOF).Printt("Read {file} failed", "file", "a.txt")
+	l.Success().Printt("Read {file}", "file", "b.txt")
+	l.On(io.ErrUnexpectedEOF).Printt("Read {file} failed", "file", "c.txt")
+	exp := []string{
+		"ERROR Read a.txt failed file=a.txt template=Read {file} failed event_type=" + parseTemplate("Read {file} failed").eventType + " - error=EOF",
+		"NOTICE Read b.txt file=b.txt template=Read {file} event_type=" + parseTemplate("Read {file}").eventType + " - success",
+		"WARNING Read c.txt failed file=c.txt error_rule=short template=Read {file} failed event_type=" + parseTemplate("Read {file} failed").eventType + " - error=unexpected EOF",
+	}
+	if res := f.lines(); strings.Join(res, "|") != strings.Join(exp, "|") {
+		tst.Errorf("fail: expected %q, but had %q", exp, res)
+	}
+}
+
+func TestTemplateCacheLimit(tst *testing.T) {
+	defer templates.Range(func(k, _ interface{}) bool {
+		if strings.HasPrefix(k.(string), "Cache limit ") {
+			templates.Delete(k)
+			atomic.AddInt32(&templateCount, -1)
+		}
+		return true
+	})
+	for i := 0; i < maxTemplates+10; i++ {
+		parseTemplate(fmt.Sprintf("Cache limit {n} %d", i))
+	}
+	if c := atomic.LoadInt32(&templateCount); c != maxTemplates {
+		tst.Errorf("fail: expected %d cached templates, but had %d", maxTemplates, c)
+	}
+}
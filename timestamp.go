@@ -0,0 +1,138 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Clock supplies record timestamps.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to Clock, e.g. for freezing time in tests.
+type ClockFunc func() time.Time
+
+func (this ClockFunc) Now() time.Time {
+	return this()
+}
+
+type systemClock struct{}
+
+func (this systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock is the default clock.
+var SystemClock Clock = systemClock{}
+
+// Timestamp layouts. Besides these any time.Format layout can be used.
+const (
+	// Same as log.Ldate|log.Ltime.
+	TimeDefault = "2006/01/02 15:04:05"
+	TimeRFC3339 = time.RFC3339
+	// RFC 3339 with fixed width fractional seconds.
+	TimeRFC3339Micro = "2006-01-02T15:04:05.000000Z07:00"
+	TimeRFC3339Nano  = "2006-01-02T15:04:05.000000000Z07:00"
+	// Unix epoch numbers.
+	TimeUnix      = "unix"
+	TimeUnixMilli = "unix-ms"
+	TimeUnixMicro = "unix-us"
+	TimeUnixNano  = "unix-ns"
+	// No timestamp.
+	TimeNone = "none"
+)
+
+var timeLayoutNames = map[string]string{
+	"default":       TimeDefault,
+	"rfc3339":       TimeRFC3339,
+	"rfc3339-micro": TimeRFC3339Micro,
+	"rfc3339-nano":  TimeRFC3339Nano,
+}
+
+type timeFormat struct {
+	layout string
+	loc    *time.Location
+}
+
+// WithClock makes the logger take record timestamps from c.
+func WithClock(c Clock) Option {
+	return func(l *sLogger) {
+		l.clock = c
+	}
+}
+
+// WithTimeFormat sets layout and location of record timestamps.
+// Nil location leaves timestamps in local time. Facilities that stamp
+// records themselves, such as syslog, are not affected.
+// By default timestamps follow log.Ldate, log.Ltime, log.Lmicroseconds
+// and log.LUTC flags of facility loggers.
+func WithTimeFormat(layout string, loc *time.Location) Option {
+	return func(l *sLogger) {
+		l.time = &timeFormat{layout: layout, loc: loc}
+	}
+}
+
+// parseTimeFormat resolves layout names used in configuration:
+// "default", "rfc3339", "rfc3339-micro", "rfc3339-nano", "unix", "unix-ms",
+// "unix-us", "unix-ns" and "none". Any other value is taken to be a layout.
+// Zone is "Local", "UTC" or a time zone database name.
+func parseTimeFormat(layout string, zone string) (*timeFormat, error) {
+	res := &timeFormat{layout: layout}
+	if l, ok := timeLayoutNames[strings.ToLower(layout)]; ok {
+		res.layout = l
+	}
+	if len(zone) > 0 {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported time zone: %q", zone)
+		}
+		res.loc = loc
+	}
+	return res, nil
+}
+
+// flagsTimeFormat returns time format that matches log package flags.
+func flagsTimeFormat(flags int) *timeFormat {
+	res := &timeFormat{}
+	if flags&log.Ldate != 0 {
+		res.layout = "2006/01/02"
+	}
+	if flags&(log.Ltime|log.Lmicroseconds) != 0 {
+		if len(res.layout) > 0 {
+			res.layout += " "
+		}
+		res.layout += "15:04:05"
+		if flags&log.Lmicroseconds != 0 {
+			res.layout += ".000000"
+		}
+	}
+	if flags&log.LUTC != 0 {
+		res.loc = time.UTC
+	}
+	return res
+}
+
+func (this *timeFormat) append(buf []byte, t time.Time) []byte {
+	if this.loc != nil {
+		t = t.In(this.loc)
+	}
+	switch this.layout {
+	case TimeNone, "":
+		return buf
+	case TimeUnix:
+		return strconv.AppendInt(buf, t.Unix(), 10)
+	case TimeUnixMilli:
+		return strconv.AppendInt(buf, t.UnixMilli(), 10)
+	case TimeUnixMicro:
+		return strconv.AppendInt(buf, t.UnixMicro(), 10)
+	case TimeUnixNano:
+		return strconv.AppendInt(buf, t.UnixNano(), 10)
+	}
+	return t.AppendFormat(buf, this.layout)
+}
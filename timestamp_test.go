@@ -0,0 +1,42 @@
+// Copyright 2016 Aleksey Blinov. All rights reserved.
+
+package slog
+
+import (
+	"log"
+	"testing"
+	"time"
+)
+
+func TestTimestamps(tst *testing.T) {
+	t1 := time.Date(2016, time.February, 21, 21, 3, 37, 1500, time.UTC)
+	clock := WithClock(ClockFunc(func() time.Time { return t1 }))
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		tst.Skip(err)
+	}
+	for _, t := range []struct {
+		flags int
+		opt   Option
+		res   string
+	}{
+		{log.Ldate | log.Ltime | log.LUTC, nil, "INFO 2016/02/21 21:03:37 msg"},
+		{log.Ltime | log.Lmicroseconds | log.LUTC, nil, "INFO 21:03:37.000001 msg"},
+		{log.Ldate | log.Ltime, WithTimeFormat(TimeRFC3339Nano, time.UTC), "INFO 2016-02-21T21:03:37.000001500Z msg"},
+		{log.Ldate | log.Ltime, WithTimeFormat(TimeRFC3339Micro, ny), "INFO 2016-02-21T16:03:37.000001-05:00 msg"},
+		{log.Ldate | log.Ltime, WithTimeFormat(TimeUnixMilli, nil), "INFO 1456088617000 msg"},
+		{log.Ldate | log.Ltime, WithTimeFormat(TimeNone, nil), "INFO msg"},
+		{0, WithTimeFormat(TimeRFC3339, nil), "INFO msg"},
+	} {
+		f := &tFacility{flags: t.flags}
+		opts := []Option{clock}
+		if t.opt != nil {
+			opts = append(opts, t.opt)
+		}
+		l, _ := New(f, PriorityInfo, SimpleFormatter, nil, opts...)
+		l.Info().Prints("msg")
+		if res := f.lines(); len(res) != 1 || res[0] != t.res {
+			tst.Errorf("fail: expected %q, but had %q", t.res, res)
+		}
+	}
+}